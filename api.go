@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"net/http"
 	"net/url"
+	"sync"
 	"time"
 
 	"github.com/pkg/errors"
@@ -24,6 +25,14 @@ var (
 	Nonce   = int64(0)
 )
 
+// Fills and the other categories here are the transaction history categories that korbit
+// accepts.
+var (
+	Fills = "fills"
+	Fiats = "fiats"
+	Coins = "coins"
+)
+
 // BTCKRW and other symbols here are the ticker names that korbit uses.
 const (
 	BTCKRW = "btc_krw"
@@ -37,6 +46,9 @@ const (
 	XRP    = "xrp"
 )
 
+// KST is the korean time zone that korbit runs in.
+var KST = time.FixedZone("KST", 9*60*60)
+
 // currencies are the currencies that are currently active on the exchange.
 var currencies = []string{BTCKRW, ETHKRW, ETCKRW}
 
@@ -44,7 +56,15 @@ var currencies = []string{BTCKRW, ETHKRW, ETCKRW}
 var (
 	LoginURL           = "https://api.korbit.co.kr/v1/oauth2/access_token"
 	BalancesURL        = "https://api.korbit.co.kr/v1/user/balances"
+	WalletURL          = "https://api.korbit.co.kr/v1/user/wallet"
+	AssignAddress      = "https://api.korbit.co.kr/v1/user/coins/address/assign"
 	BtcWithdrawal      = "https://api.korbit.co.kr/v1/user/coins/out"
+	CoinStatus         = "https://api.korbit.co.kr/v1/user/coins/status"
+	CancelWithdrawal   = "https://api.korbit.co.kr/v1/user/coins/out/cancel"
+	RegisterBank       = "https://api.korbit.co.kr/v1/user/fiats/address/register"
+	FiatWithdrawal     = "https://api.korbit.co.kr/v1/user/fiats/out"
+	FiatStatus         = "https://api.korbit.co.kr/v1/user/fiats/status"
+	CancelFiatOut      = "https://api.korbit.co.kr/v1/user/fiats/out/cancel"
 	PlaceBid           = "https://api.korbit.co.kr/v1/user/orders/buy"
 	PlaceAsk           = "https://api.korbit.co.kr/v1/user/orders/sell"
 	CancelOpenOrders   = "https://api.korbit.co.kr/v1/user/orders/cancel"
@@ -52,17 +72,24 @@ var (
 	TransactionHistory = "https://api.korbit.co.kr/v1/user/transactions"
 	TradeVolumeAndFees = "https://api.korbit.co.kr/v1/user/volume"
 	GetOrderbook       = "https://api.korbit.co.kr/v1/orderbook"
+	DetailedTicker     = "https://api.korbit.co.kr/v1/ticker/detailed"
+	AllTickers         = "https://api.korbit.co.kr/v1/ticker/detailed/all"
+	PublicTrades       = "https://api.korbit.co.kr/v1/transactions"
 )
 
-// API is the object that holds the client and has all of the API methods.
+// API is the object that holds the client and has all of the API methods. Login and
+// RefreshToken replace the Token, so when the API is shared between goroutines the token
+// should be read with CurrentToken rather than from the field.
 type API struct {
 	Token        *Token
+	tokenMu      sync.RWMutex // guards Token
 	Client       *http.Client
 	Nonce        int64
 	ClientID     string
 	ClientSecret string
 	Username     string
 	Password     string
+	Location     *time.Location // location that decoded timestamps are given in
 }
 
 // Token has the token and refresh token which takes care of the authentication
@@ -84,6 +111,7 @@ func NewKorbitAPI(clientID, clientSecret, username, password string) *API {
 		Password:     password,
 		Client:       &http.Client{},
 		Nonce:        time.Now().Unix(),
+		Location:     time.UTC,
 	}
 
 	return &api
@@ -113,11 +141,38 @@ func (k *API) Login() error {
 	}
 
 	token.Timestamp = time.Now()
-	k.Token = &token
+	k.setToken(&token)
 
 	return nil
 }
 
+// CurrentToken gives the token of the last login or refresh, nil before logging in. The
+// token is never changed in place, a new one is set instead.
+func (k *API) CurrentToken() *Token {
+	k.tokenMu.RLock()
+	defer k.tokenMu.RUnlock()
+	return k.Token
+}
+
+func (k *API) setToken(token *Token) {
+	k.tokenMu.Lock()
+	k.Token = token
+	k.tokenMu.Unlock()
+}
+
+// millisToTime turns the millisecond timestamps that korbit uses into a time.
+func millisToTime(ms int64) time.Time {
+	return time.Unix(0, ms*int64(time.Millisecond))
+}
+
+// location gives the location that timestamps should be given in, UTC if none is set.
+func (k *API) location() *time.Location {
+	if k == nil || k.Location == nil {
+		return time.UTC
+	}
+	return k.Location
+}
+
 // GetNonce returns an ever increasing nonce for the requests to the API.
 func (k *API) GetNonce() string {
 	k.Nonce++
@@ -127,8 +182,9 @@ func (k *API) GetNonce() string {
 // ShouldRefresh returns true if there is less than 10 minutes left on the token
 // before it will expire.
 func (k *API) ShouldRefresh() bool {
-	minusTen := time.Duration(k.Token.ExpiresIn - 600)
-	tenMinsLeft := k.Token.Timestamp.Add(minusTen * time.Second)
+	token := k.CurrentToken()
+	minusTen := time.Duration(token.ExpiresIn - 600)
+	tenMinsLeft := token.Timestamp.Add(minusTen * time.Second)
 	return time.Now().After(tenMinsLeft)
 }
 
@@ -142,8 +198,10 @@ func (k *API) NewRequest(url, method string, body url.Values) (*http.Request, er
 		return nil, errors.Wrap(err, "make korbit req")
 	}
 
-	token := fmt.Sprintf("%s %s", k.Token.TokenType, k.Token.AccessToken)
-	req.Header.Set("Authorization", token)
+	// public endpoints can be used without logging in.
+	if token := k.CurrentToken(); token != nil {
+		req.Header.Set("Authorization", fmt.Sprintf("%s %s", token.TokenType, token.AccessToken))
+	}
 
 	if method == "POST" {
 		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
@@ -158,7 +216,7 @@ func (k *API) RefreshToken() error {
 	body := url.Values{
 		"client_id":     {k.ClientID},
 		"client_secret": {k.ClientSecret},
-		"refresh_token": {k.Token.RefreshToken},
+		"refresh_token": {k.CurrentToken().RefreshToken},
 		"grant_type":    {"refresh_token"},
 	}
 
@@ -175,7 +233,7 @@ func (k *API) RefreshToken() error {
 	}
 
 	token.Timestamp = time.Now()
-	k.Token = &token
+	k.setToken(&token)
 
 	return nil
 }
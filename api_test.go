@@ -0,0 +1,95 @@
+package korbit
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// newTestAPI returns an API that is already logged in and a server that answers its
+// requests with the handler. The url variable that is pointed at the server is restored
+// when the test finishes.
+func newTestAPI(t *testing.T, endpoint *string, handler http.HandlerFunc) *API {
+	srv := httptest.NewServer(handler)
+
+	old := *endpoint
+	*endpoint = srv.URL
+	t.Cleanup(func() {
+		*endpoint = old
+		srv.Close()
+	})
+
+	k := NewKorbitAPI("id", "secret", "user", "pass")
+	k.Token = &Token{
+		AccessToken: "token",
+		TokenType:   "Bearer",
+		ExpiresIn:   3600,
+		Timestamp:   time.Now(),
+	}
+
+	return k
+}
+
+func TestShouldRefresh(t *testing.T) {
+	k := NewKorbitAPI("", "", "", "")
+	k.Token = &Token{ExpiresIn: 3600, Timestamp: time.Now()}
+	if k.ShouldRefresh() {
+		t.Error("fresh token should not need a refresh")
+	}
+
+	k.Token.Timestamp = time.Now().Add(-55 * time.Minute)
+	if !k.ShouldRefresh() {
+		t.Error("token with 5 minutes left should be refreshed")
+	}
+}
+
+func TestPublicRequestWithoutLogin(t *testing.T) {
+	k := newTestAPI(t, &PublicTrades, func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "" {
+			t.Errorf("unexpected authorization header: %s", r.Header.Get("Authorization"))
+		}
+		fmt.Fprint(w, `[{"timestamp": 1500000000000, "tid": "1", "price": "9198500", "amount": "0.1", "type": "buy"}]`)
+	})
+	k.Token = nil
+
+	trades, err := k.GetTrades(BTCKRW, Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(trades) != 1 || trades[0].Price != 9198500 {
+		t.Errorf("unexpected trades: %+v", trades)
+	}
+}
+
+func TestRefreshTokenConcurrent(t *testing.T) {
+	k := newTestAPI(t, &LoginURL, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"access_token": "refreshed", "token_type": "Bearer", "expires_in": 3600}`)
+	})
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 10; i++ {
+			if err := k.RefreshToken(); err != nil {
+				t.Error(err)
+			}
+		}
+	}()
+
+	for i := 0; i < 10; i++ {
+		req, err := k.NewRequest(DetailedTicker, "GET", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if auth := req.Header.Get("Authorization"); auth != "Bearer token" && auth != "Bearer refreshed" {
+			t.Errorf("unexpected authorization header: %s", auth)
+		}
+	}
+	<-done
+
+	if k.CurrentToken().AccessToken != "refreshed" {
+		t.Errorf("token not refreshed: %+v", k.CurrentToken())
+	}
+}
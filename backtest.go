@@ -0,0 +1,326 @@
+package korbit
+
+import (
+	"encoding/csv"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Strategy is called with every market event of a backtest, after the simulated exchange
+// has seen the event. It trades through the Trader so the same strategy can run against
+// a PaperExchange or the API. An error stops the backtest.
+type Strategy interface {
+	OnEvent(e MarketEvent, t Trader) error
+}
+
+// StrategyFunc lets a function be used as a Strategy.
+type StrategyFunc func(e MarketEvent, t Trader) error
+
+// OnEvent calls f.
+func (f StrategyFunc) OnEvent(e MarketEvent, t Trader) error {
+	return f(e, t)
+}
+
+// tradingYear is what returns are annualized over, crypto trades every day of the year.
+const tradingYear = 365 * 24 * time.Hour
+
+// Backtest replays recorded market data through a strategy against a PaperExchange. The
+// fill assumptions are the ones of PaperExchange, with the tick sizes of Precisions.
+type Backtest struct {
+	Strategy      Strategy
+	Balances      Balances // the balances at the start
+	MakerFee      float64
+	TakerFee      float64
+	Latency       time.Duration
+	QueuePosition bool
+	SampleEvery   time.Duration // how often the equity is sampled
+}
+
+// NewBacktest returns a backtest of the strategy that samples the equity every minute.
+func NewBacktest(strategy Strategy, balances Balances) *Backtest {
+	return &Backtest{
+		Strategy:    strategy,
+		Balances:    balances,
+		SampleEvery: time.Minute,
+	}
+}
+
+// EquityPoint is the KRW value of the balances at a time, coins are valued at the mid of
+// the last orderbook or the last trade price.
+type EquityPoint struct {
+	Time   time.Time
+	Equity float64
+}
+
+// BacktestTrade is a fill of the strategy. FeeKRW is the fee valued at the fill price.
+type BacktestTrade struct {
+	Time         time.Time
+	CurrencyPair string
+	Side         string
+	OrderID      int64
+	Price        float64
+	Qty          float64
+	Notional     float64
+	Fee          float64
+	FeeCurrency  string
+	FeeKRW       float64
+}
+
+// BacktestStats are the performance statistics of a backtest. Return and MaxDrawdown are
+// fractions, Sharpe is annualized from the sampled returns without a risk free rate and
+// Turnover is the traded notional over the average equity.
+type BacktestStats struct {
+	StartEquity float64
+	EndEquity   float64
+	Return      float64
+	Sharpe      float64
+	MaxDrawdown float64
+	Turnover    float64
+	Trades      int
+	FeesKRW     float64
+}
+
+// BacktestResult is what a backtest produced.
+type BacktestResult struct {
+	Equity []EquityPoint
+	Trades []BacktestTrade
+	Stats  BacktestStats
+}
+
+// Run replays the events until they run out and gives the result.
+func (b *Backtest) Run(events EventSource) (*BacktestResult, error) {
+	if b.Strategy == nil {
+		return nil, errors.New("backtest needs a strategy")
+	}
+	if b.SampleEvery <= 0 {
+		return nil, errors.New("backtest needs a positive sample interval")
+	}
+
+	ex := NewPaperExchange(b.Balances, b.MakerFee, b.TakerFee)
+	ex.Latency = b.Latency
+	ex.QueuePosition = b.QueuePosition
+
+	var res BacktestResult
+	marks := map[string]float64{}
+	var next, last time.Time
+
+	for {
+		e, err := events.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, "backtest events")
+		}
+
+		t := e.Time()
+		if t.IsZero() {
+			continue
+		}
+
+		ex.Apply(e)
+		updateMark(marks, e)
+
+		err = b.Strategy.OnEvent(e, ex)
+		if err != nil {
+			return nil, errors.Wrapf(err, "strategy at %s", t)
+		}
+
+		last = t
+		if next.IsZero() || !t.Before(next) {
+			res.Equity = append(res.Equity, EquityPoint{Time: t, Equity: ex.equity(marks)})
+			next = t.Add(b.SampleEvery)
+		}
+	}
+
+	if len(res.Equity) == 0 {
+		return nil, errors.New("backtest had no market events")
+	}
+	if end := res.Equity[len(res.Equity)-1]; last.After(end.Time) {
+		res.Equity = append(res.Equity, EquityPoint{Time: last, Equity: ex.equity(marks)})
+	}
+
+	res.Trades = ex.trades()
+	res.Stats = backtestStats(res.Equity, res.Trades, b.SampleEvery)
+	return &res, nil
+}
+
+// updateMark keeps the price that the coin of the event's pair is valued at.
+func updateMark(marks map[string]float64, e MarketEvent) {
+	switch e.Type {
+	case OrderbookEvent:
+		if mid, err := e.Orderbook.Mid(); err == nil {
+			marks[e.CurrencyPair] = mid
+		}
+	case TradeEvent:
+		marks[e.CurrencyPair] = float64(e.Trade.Price)
+	case TickerEvent:
+		marks[e.CurrencyPair] = float64(e.Ticker.Last)
+	}
+}
+
+// equity values all of the balances in KRW at the marks, coins without a mark count as
+// nothing.
+func (p *PaperExchange) equity(marks map[string]float64) float64 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var total float64
+	for c, b := range p.balances {
+		amount := b.Available + b.TradeInuse + b.WithdrawalInUse
+		if c == KRW {
+			total += amount
+			continue
+		}
+		total += amount * marks[c+"_"+KRW]
+	}
+	return total
+}
+
+// trades gives every fill of the exchange in the order they happened.
+func (p *PaperExchange) trades() []BacktestTrade {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var trades []BacktestTrade
+	for pair, fills := range p.fills {
+		for _, f := range fills {
+			t := BacktestTrade{
+				Time:         millisToTime(f.Timestamp),
+				CurrencyPair: pair,
+				Side:         f.Type,
+				OrderID:      f.FillsDetail.OrderID,
+				Price:        f.FillsDetail.Price.Value,
+				Qty:          f.FillsDetail.Amount.Value,
+				Notional:     f.FillsDetail.NativeAmount.Value,
+				Fee:          f.Fee.Value,
+				FeeCurrency:  f.Fee.Currency,
+				FeeKRW:       f.Fee.Value,
+			}
+			if f.Fee.Currency != KRW {
+				t.FeeKRW = f.Fee.Value * t.Price
+			}
+			trades = append(trades, t)
+		}
+	}
+
+	sort.SliceStable(trades, func(i, j int) bool {
+		if !trades[i].Time.Equal(trades[j].Time) {
+			return trades[i].Time.Before(trades[j].Time)
+		}
+		return trades[i].OrderID < trades[j].OrderID
+	})
+	return trades
+}
+
+// backtestStats works out the statistics from the equity curve and the trades.
+func backtestStats(equity []EquityPoint, trades []BacktestTrade, every time.Duration) BacktestStats {
+	s := BacktestStats{
+		StartEquity: equity[0].Equity,
+		EndEquity:   equity[len(equity)-1].Equity,
+		Trades:      len(trades),
+	}
+	if s.StartEquity > 0 {
+		s.Return = s.EndEquity/s.StartEquity - 1
+	}
+
+	var returns []float64
+	var sum, peak float64
+	for i, p := range equity {
+		sum += p.Equity
+		if p.Equity > peak {
+			peak = p.Equity
+		}
+		if peak > 0 {
+			s.MaxDrawdown = math.Max(s.MaxDrawdown, (peak-p.Equity)/peak)
+		}
+		if i > 0 && equity[i-1].Equity > 0 {
+			returns = append(returns, p.Equity/equity[i-1].Equity-1)
+		}
+	}
+
+	if len(returns) > 1 {
+		var mean float64
+		for _, r := range returns {
+			mean += r
+		}
+		mean /= float64(len(returns))
+
+		var variance float64
+		for _, r := range returns {
+			variance += (r - mean) * (r - mean)
+		}
+		std := math.Sqrt(variance / float64(len(returns)-1))
+		if std > 0 {
+			s.Sharpe = mean / std * math.Sqrt(float64(tradingYear)/float64(every))
+		}
+	}
+
+	var notional float64
+	for _, t := range trades {
+		notional += t.Notional
+		s.FeesKRW += t.FeeKRW
+	}
+	if avg := sum / float64(len(equity)); avg > 0 {
+		s.Turnover = notional / avg
+	}
+
+	return s
+}
+
+// WriteEquityCSV writes the equity curve as CSV with a header.
+func (r *BacktestResult) WriteEquityCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	err := cw.Write([]string{"time", "equity"})
+	if err != nil {
+		return errors.Wrap(err, "write equity header")
+	}
+
+	for _, p := range r.Equity {
+		err = cw.Write([]string{p.Time.UTC().Format(time.RFC3339Nano), strconv.FormatFloat(p.Equity, 'f', -1, 64)})
+		if err != nil {
+			return errors.Wrap(err, "write equity point")
+		}
+	}
+
+	cw.Flush()
+	return errors.Wrap(cw.Error(), "flush equity")
+}
+
+// WriteTradesCSV writes the trade log as CSV with a header.
+func (r *BacktestResult) WriteTradesCSV(w io.Writer) error {
+	f := func(v float64) string { return strconv.FormatFloat(v, 'f', -1, 64) }
+
+	cw := csv.NewWriter(w)
+	err := cw.Write([]string{"time", "currency_pair", "side", "order_id", "price", "qty", "notional",
+		"fee", "fee_currency", "fee_krw"})
+	if err != nil {
+		return errors.Wrap(err, "write trades header")
+	}
+
+	for _, t := range r.Trades {
+		err = cw.Write([]string{
+			t.Time.UTC().Format(time.RFC3339Nano),
+			t.CurrencyPair,
+			t.Side,
+			strconv.FormatInt(t.OrderID, 10),
+			f(t.Price),
+			f(t.Qty),
+			f(t.Notional),
+			f(t.Fee),
+			t.FeeCurrency,
+			f(t.FeeKRW),
+		})
+		if err != nil {
+			return errors.Wrap(err, "write trade")
+		}
+	}
+
+	cw.Flush()
+	return errors.Wrap(cw.Error(), "flush trades")
+}
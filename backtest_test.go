@@ -0,0 +1,197 @@
+package korbit
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func bookEvent(ms int64, bid, ask int64) MarketEvent {
+	return MarketEvent{
+		Type:         OrderbookEvent,
+		CurrencyPair: BTCKRW,
+		Orderbook: &Orderbook{
+			CurrencyPair: BTCKRW,
+			Timestamp:    ms,
+			Bids:         []OrderbookOrder{{Price: bid, Qty: 0.5}},
+			Asks:         []OrderbookOrder{{Price: ask, Qty: 0.5}},
+		},
+	}
+}
+
+func tradeEvent(ms, tid, price int64, amount float64) MarketEvent {
+	return MarketEvent{
+		Type:         TradeEvent,
+		CurrencyPair: BTCKRW,
+		Trade:        &Trade{CurrencyPair: BTCKRW, Timestamp: ms, TID: tid, Price: price, Amount: amount},
+	}
+}
+
+// sliceEvents is an EventSource over a slice.
+type sliceEvents []MarketEvent
+
+func (s *sliceEvents) Next() (MarketEvent, error) {
+	if len(*s) == 0 {
+		return MarketEvent{}, io.EOF
+	}
+	e := (*s)[0]
+	*s = (*s)[1:]
+	return e, nil
+}
+
+func TestEventRoundTrip(t *testing.T) {
+	ticker := &Prices{TimestampMillis: 1500000000000, Last: 3000000, Volume: 12.5}
+	events := []MarketEvent{
+		{Type: TickerEvent, CurrencyPair: BTCKRW, Ticker: ticker},
+		bookEvent(1500000001000, 3000000, 3000500),
+		tradeEvent(1500000002000, 7, 3000500, 0.25),
+	}
+
+	var buf bytes.Buffer
+	w := NewEventWriter(&buf)
+	for _, e := range events {
+		if err := w.Write(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	r := NewEventReader(&buf)
+	for i, want := range events {
+		got, err := r.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Type != want.Type || got.CurrencyPair != want.CurrencyPair || !got.Time().Equal(want.Time()) {
+			t.Errorf("event %d: got %+v want %+v", i, got, want)
+		}
+	}
+	if _, err := r.Next(); err != io.EOF {
+		t.Errorf("expected EOF, got %v", err)
+	}
+
+	r = NewEventReader(strings.NewReader("{\"type\": \"trade\", \"trade\": {\"price\": 1}}\n"))
+	if _, err := r.Next(); err == nil {
+		t.Error("expected error for a malformed trade")
+	}
+}
+
+func TestMergeEvents(t *testing.T) {
+	a := sliceEvents{tradeEvent(1000, 1, 1, 1), tradeEvent(3000, 3, 1, 1)}
+	b := sliceEvents{tradeEvent(2000, 2, 1, 1), tradeEvent(4000, 4, 1, 1)}
+
+	merged := MergeEvents(&a, &b)
+	for want := int64(1); ; want++ {
+		e, err := merged.Next()
+		if err == io.EOF {
+			if want != 5 {
+				t.Errorf("merged %d events", want-1)
+			}
+			break
+		}
+		if e.Trade.TID != want {
+			t.Fatalf("got tid %d want %d", e.Trade.TID, want)
+		}
+	}
+}
+
+func TestBacktestQueuePosition(t *testing.T) {
+	placed := false
+	strategy := StrategyFunc(func(e MarketEvent, tr Trader) error {
+		if placed || e.Type != OrderbookEvent {
+			return nil
+		}
+		placed = true
+		_, err := tr.Buy(&OrderArgs{CurrencyPair: BTCKRW, Type: Limit, Price: 3000000, CoinAmount: "0.1"})
+		return err
+	})
+
+	events := sliceEvents{
+		bookEvent(1500000000000, 3000000, 3000500),
+		tradeEvent(1500000060000, 1, 3000000, 0.4), // only eats into the 0.5 in front
+		tradeEvent(1500000120000, 2, 3000000, 0.3),
+		bookEvent(1500000180000, 3010000, 3010500),
+	}
+
+	bt := NewBacktest(strategy, Balances{KRW: {Available: 1000000}})
+	bt.MakerFee = 0.001
+	bt.QueuePosition = true
+	res, err := bt.Run(&events)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(res.Trades) != 1 {
+		t.Fatalf("unexpected trades: %+v", res.Trades)
+	}
+	tr := res.Trades[0]
+	if !near(tr.Qty, 0.1) || tr.Price != 3000000 || !near(tr.FeeKRW, 300) ||
+		!tr.Time.Equal(millisToTime(1500000120000)) {
+		t.Errorf("unexpected trade: %+v", tr)
+	}
+
+	if len(res.Equity) != 4 || res.Stats.StartEquity != 1000000 {
+		t.Errorf("unexpected equity: %+v", res.Equity)
+	}
+	want := 700000 + 0.0999*3010250
+	if !near(res.Stats.EndEquity, want) || res.Stats.Trades != 1 || !near(res.Stats.FeesKRW, 300) {
+		t.Errorf("unexpected stats: %+v", res.Stats)
+	}
+
+	var buf bytes.Buffer
+	if err := res.WriteTradesCSV(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Count(buf.String(), "\n"); lines != 2 {
+		t.Errorf("unexpected trade log: %s", buf.String())
+	}
+}
+
+func TestBacktestLatency(t *testing.T) {
+	var ex Trader
+	strategy := StrategyFunc(func(e MarketEvent, tr Trader) error {
+		if ex != nil {
+			return nil
+		}
+		ex = tr
+		// would take the ask right away without latency.
+		_, err := tr.Buy(&OrderArgs{CurrencyPair: BTCKRW, Type: Limit, Price: 3000500, CoinAmount: "0.1"})
+		return err
+	})
+
+	events := sliceEvents{
+		bookEvent(1500000000000, 3000000, 3000500),
+		bookEvent(1500000001000, 3001500, 3002000),
+		bookEvent(1500000003000, 3001500, 3002000),
+	}
+
+	bt := NewBacktest(strategy, Balances{KRW: {Available: 1000000}})
+	bt.Latency = 2 * time.Second
+	res, err := bt.Run(&events)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(res.Trades) != 0 {
+		t.Errorf("expected no fills: %+v", res.Trades)
+	}
+	orders, _ := ex.ListOpenOrders(BTCKRW)
+	if len(*orders) != 1 {
+		t.Errorf("expected the order to rest: %+v", *orders)
+	}
+}
+
+func TestBacktestStats(t *testing.T) {
+	start := time.Unix(0, 0)
+	var equity []EquityPoint
+	for i, v := range []float64{100, 110, 99, 120} {
+		equity = append(equity, EquityPoint{Time: start.Add(time.Duration(i) * time.Hour), Equity: v})
+	}
+	trades := []BacktestTrade{{Notional: 214.5}}
+
+	s := backtestStats(equity, trades, time.Hour)
+	if !near(s.Return, 0.2) || !near(s.MaxDrawdown, 0.1) || !near(s.Turnover, 2) || s.Sharpe <= 0 {
+		t.Errorf("unexpected stats: %+v", s)
+	}
+}
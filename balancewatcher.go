@@ -0,0 +1,262 @@
+package korbit
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// BalanceDeposit and the other kinds here say what a BalanceEvent most likely was. They
+// are guessed from how the fields of the balance moved between two polls.
+const (
+	BalanceDeposit           = "deposit"            // available went up from outside of trading
+	BalanceReserved          = "reserved"           // funds were locked for an order
+	BalanceReleased          = "released"           // locked funds came back to available
+	BalanceWithdrawalPending = "withdrawal_pending" // funds were locked for a withdrawal
+	BalanceWithdrawn         = "withdrawn"          // a pending withdrawal left the account
+	BalanceChanged           = "changed"            // anything else, like order fills
+	BalanceAlert             = "alert"              // available crossed an alert threshold
+)
+
+// balanceSlack is how much a balance field has to move to count as a change.
+const balanceSlack = 1e-9
+
+// BalanceEvent is published by a BalanceWatcher for a currency whose balance changed or
+// that crossed an alert threshold. Delta is New minus Old field by field.
+type BalanceEvent struct {
+	Currency  string
+	Kind      string
+	Old       Balance_
+	New       Balance_
+	Delta     Balance_
+	Threshold float64 // the crossed threshold of an alert
+	Time      time.Time
+}
+
+// balanceAlert is a threshold on the available balance of a currency.
+type balanceAlert struct {
+	currency  string
+	threshold float64
+	below     bool // alert when going below rather than above
+	active    bool // the balance is past the threshold
+}
+
+// BalanceWatcher polls the balances and publishes an event for every currency that
+// changed. When a poll sees funds leave orders for one currency, rises in available for
+// the others are taken to be fills rather than deposits.
+type BalanceWatcher struct {
+	Interval time.Duration
+
+	fetch  func() (Balances, error)
+	events chan BalanceEvent
+	errors chan error
+	stop   chan struct{}
+	once   sync.Once
+
+	mu      sync.Mutex
+	started bool
+	last    Balances
+	alerts  []*balanceAlert
+}
+
+// NewBalanceWatcher returns a watcher that polls the balances on the interval. Nothing is
+// polled until Start is called.
+func NewBalanceWatcher(k *API, interval time.Duration) *BalanceWatcher {
+	return &BalanceWatcher{
+		Interval: interval,
+		fetch:    k.GetBalances,
+		events:   make(chan BalanceEvent, 16),
+		errors:   make(chan error, 1),
+		stop:     make(chan struct{}),
+	}
+}
+
+// AlertBelow publishes an alert each time the available balance of the currency goes
+// below the threshold, including when it already is at the first poll.
+func (w *BalanceWatcher) AlertBelow(currency string, threshold float64) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.alerts = append(w.alerts, &balanceAlert{currency: currency, threshold: threshold, below: true})
+}
+
+// AlertAbove publishes an alert each time the available balance of the currency goes
+// above the threshold, including when it already is at the first poll.
+func (w *BalanceWatcher) AlertAbove(currency string, threshold float64) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.alerts = append(w.alerts, &balanceAlert{currency: currency, threshold: threshold})
+}
+
+// Events gives the channel the events are published on, it is closed after Stop.
+func (w *BalanceWatcher) Events() <-chan BalanceEvent {
+	return w.events
+}
+
+// Errors gives the channel that polling errors are sent on. Errors are dropped while
+// the previous one has not been received.
+func (w *BalanceWatcher) Errors() <-chan error {
+	return w.errors
+}
+
+// Start polls in the background until Stop is called, it does nothing when it was
+// started already.
+func (w *BalanceWatcher) Start() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.started {
+		return
+	}
+	w.started = true
+
+	go func() {
+		defer close(w.events)
+
+		ticker := time.NewTicker(w.Interval)
+		defer ticker.Stop()
+
+		for {
+			w.poll()
+
+			select {
+			case <-w.stop:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Stop stops the polling, it is fine to call more than once.
+func (w *BalanceWatcher) Stop() {
+	w.once.Do(func() { close(w.stop) })
+}
+
+func (w *BalanceWatcher) poll() {
+	balances, err := w.fetch()
+	if err != nil {
+		select {
+		case w.errors <- err:
+		default:
+		}
+		return
+	}
+
+	for _, e := range w.next(balances, time.Now()) {
+		select {
+		case w.events <- e:
+		case <-w.stop:
+			return
+		}
+	}
+}
+
+// next compares the balances against the last poll and gives the events for them, sorted
+// by currency with the alerts last.
+func (w *BalanceWatcher) next(balances Balances, now time.Time) []BalanceEvent {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	var events []BalanceEvent
+	if w.last != nil {
+		events = diffBalances(w.last, balances, now)
+	}
+	w.last = balances
+
+	for _, a := range w.alerts {
+		b, ok := balances[a.currency]
+		if !ok {
+			continue
+		}
+
+		past := b.Available > a.threshold
+		if a.below {
+			past = b.Available < a.threshold
+		}
+		if past && !a.active {
+			events = append(events, BalanceEvent{
+				Currency:  a.currency,
+				Kind:      BalanceAlert,
+				New:       b,
+				Threshold: a.threshold,
+				Time:      now,
+			})
+		}
+		a.active = past
+	}
+
+	return events
+}
+
+// diffBalances gives an event for each currency whose balance changed between the polls.
+func diffBalances(prev, cur Balances, now time.Time) []BalanceEvent {
+	var currencies []string
+	seen := map[string]bool{}
+	for _, b := range []Balances{prev, cur} {
+		for c := range b {
+			if !seen[c] {
+				seen[c] = true
+				currencies = append(currencies, c)
+			}
+		}
+	}
+	sort.Strings(currencies)
+
+	deltas := map[string]Balance_{}
+	traded := false
+	for _, c := range currencies {
+		d := Balance_{
+			Available:       cur[c].Available - prev[c].Available,
+			TradeInuse:      cur[c].TradeInuse - prev[c].TradeInuse,
+			WithdrawalInUse: cur[c].WithdrawalInUse - prev[c].WithdrawalInUse,
+		}
+		deltas[c] = d
+		if d.TradeInuse < -balanceSlack && d.Available < balanceSlack {
+			traded = true // left orders without coming back to available, so it filled
+		}
+	}
+
+	var events []BalanceEvent
+	for _, c := range currencies {
+		d := deltas[c]
+		kind := classifyBalance(d, traded)
+		if kind == "" {
+			continue
+		}
+
+		events = append(events, BalanceEvent{
+			Currency: c,
+			Kind:     kind,
+			Old:      prev[c],
+			New:      cur[c],
+			Delta:    d,
+			Time:     now,
+		})
+	}
+
+	return events
+}
+
+// classifyBalance guesses what moved the balance, empty when nothing did.
+func classifyBalance(d Balance_, traded bool) string {
+	up := func(v float64) bool { return v > balanceSlack }
+	down := func(v float64) bool { return v < -balanceSlack }
+
+	switch {
+	case up(d.WithdrawalInUse):
+		return BalanceWithdrawalPending
+	case down(d.WithdrawalInUse) && up(d.Available):
+		return BalanceReleased // the withdrawal was canceled
+	case down(d.WithdrawalInUse):
+		return BalanceWithdrawn
+	case up(d.TradeInuse):
+		return BalanceReserved
+	case down(d.TradeInuse) && up(d.Available):
+		return BalanceReleased // the order was canceled
+	case up(d.Available) && !traded:
+		return BalanceDeposit
+	case up(d.Available), down(d.Available), down(d.TradeInuse):
+		return BalanceChanged
+	}
+	return ""
+}
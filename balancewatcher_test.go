@@ -0,0 +1,85 @@
+package korbit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDiffBalances(t *testing.T) {
+	prev := Balances{
+		KRW: {Available: 1000000, TradeInuse: 500000},
+		BTC: {Available: 1},
+		ETH: {Available: 10},
+		XRP: {Available: 100},
+	}
+	cur := Balances{
+		KRW: {Available: 1000000, TradeInuse: 0}, // a buy filled
+		BTC: {Available: 1.05},                   // and brought btc
+		ETH: {Available: 8, WithdrawalInUse: 2},  // a withdrawal was requested
+		XRP: {Available: 50, TradeInuse: 50},     // an order was placed
+		ETC: {Available: 3},                      // a new coin
+	}
+
+	kinds := map[string]string{}
+	for _, e := range diffBalances(prev, cur, time.Now()) {
+		kinds[e.Currency] = e.Kind
+	}
+
+	want := map[string]string{
+		KRW: BalanceChanged,
+		BTC: BalanceChanged,
+		ETH: BalanceWithdrawalPending,
+		XRP: BalanceReserved,
+		ETC: BalanceChanged,
+	}
+	for c, kind := range want {
+		if kinds[c] != kind {
+			t.Errorf("%s: got %s, want %s", c, kinds[c], kind)
+		}
+	}
+
+	// without any trading a rise in available is a deposit.
+	events := diffBalances(Balances{BTC: {Available: 1}}, Balances{BTC: {Available: 2}}, time.Now())
+	if len(events) != 1 || events[0].Kind != BalanceDeposit || events[0].Delta.Available != 1 {
+		t.Errorf("unexpected deposit events: %+v", events)
+	}
+}
+
+func TestBalanceWatcher(t *testing.T) {
+	polls := []Balances{
+		{KRW: {Available: 50000}},
+		{KRW: {Available: 50000}},
+		{KRW: {Available: 20000, TradeInuse: 30000}},
+		{KRW: {Available: 60000, TradeInuse: 30000}},
+	}
+
+	w := NewBalanceWatcher(NewKorbitAPI("", "", "", ""), time.Millisecond)
+	w.AlertBelow(KRW, 25000)
+	w.fetch = func() (Balances, error) {
+		b := polls[0]
+		if len(polls) > 1 {
+			polls = polls[1:]
+		}
+		return b, nil
+	}
+	w.Start()
+	w.Start() // starting again must not poll twice or close the events twice
+
+	var kinds []string
+	for e := range w.Events() {
+		kinds = append(kinds, e.Kind)
+		if len(kinds) == 3 {
+			w.Stop()
+		}
+	}
+
+	want := []string{BalanceReserved, BalanceAlert, BalanceDeposit}
+	if len(kinds) != len(want) {
+		t.Fatalf("got %v, want %v", kinds, want)
+	}
+	for i := range want {
+		if kinds[i] != want[i] {
+			t.Errorf("got %v, want %v", kinds, want)
+		}
+	}
+}
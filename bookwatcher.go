@@ -0,0 +1,214 @@
+package korbit
+
+import (
+	"sync"
+	"time"
+)
+
+// LevelAdded and the other kinds here say what happened to a price level between two
+// orderbook snapshots.
+const (
+	LevelAdded   = "added"
+	LevelRemoved = "removed"
+	LevelChanged = "changed"
+)
+
+// LevelChange is the change of a single price level of the book.
+type LevelChange struct {
+	Side   string // bid or ask
+	Kind   string
+	Price  int64
+	OldQty float64
+	NewQty float64
+}
+
+// DiffOrderbooks gives the level by level changes that turn prev into next, bids first
+// and then asks, each in book order. A nil prev is treated as an empty book.
+func DiffOrderbooks(prev, next *Orderbook) []LevelChange {
+	if prev == nil {
+		prev = &Orderbook{}
+	}
+
+	changes := diffLevels(Bid, prev.Bids, next.Bids)
+	return append(changes, diffLevels(Ask, prev.Asks, next.Asks)...)
+}
+
+func diffLevels(side string, prev, next []OrderbookOrder) []LevelChange {
+	old := make(map[int64]float64, len(prev))
+	for _, l := range prev {
+		old[l.Price] = l.Qty
+	}
+
+	var changes []LevelChange
+	for _, l := range next {
+		qty, ok := old[l.Price]
+		switch {
+		case !ok:
+			changes = append(changes, LevelChange{Side: side, Kind: LevelAdded, Price: l.Price, NewQty: l.Qty})
+		case qty != l.Qty:
+			changes = append(changes, LevelChange{Side: side, Kind: LevelChanged, Price: l.Price,
+				OldQty: qty, NewQty: l.Qty})
+		}
+		delete(old, l.Price)
+	}
+
+	for _, l := range prev {
+		if qty, ok := old[l.Price]; ok {
+			changes = append(changes, LevelChange{Side: side, Kind: LevelRemoved, Price: l.Price, OldQty: qty})
+		}
+	}
+
+	return changes
+}
+
+// BookEvent is published by a BookWatcher for every new snapshot that changed the book.
+// The first event of a watcher has every level as added.
+type BookEvent struct {
+	Seq          uint64
+	CurrencyPair string
+	Timestamp    int64
+	Changes      []LevelChange
+	Book         *Orderbook
+}
+
+// BookWatcher polls the orderbook of a pair and publishes what changed between
+// consecutive snapshots. Snapshots that are not newer than the last one by Timestamp are
+// dropped and counted as stale or out of order.
+type BookWatcher struct {
+	CurrencyPair string
+	Interval     time.Duration
+
+	fetch  func(string) (*Orderbook, error)
+	events chan BookEvent
+	errors chan error
+	stop   chan struct{}
+	once   sync.Once
+
+	mu         sync.Mutex
+	started    bool
+	seq        uint64
+	last       *Orderbook
+	stale      int
+	outOfOrder int
+}
+
+// NewBookWatcher returns a watcher that polls the orderbook of the pair on the interval.
+// Nothing is polled until Start is called.
+func NewBookWatcher(k *API, pair string, interval time.Duration) *BookWatcher {
+	return &BookWatcher{
+		CurrencyPair: pair,
+		Interval:     interval,
+		fetch:        k.GetOrderbook,
+		events:       make(chan BookEvent, 16),
+		errors:       make(chan error, 1),
+		stop:         make(chan struct{}),
+	}
+}
+
+// Events gives the channel the changes are published on, it is closed after Stop.
+func (w *BookWatcher) Events() <-chan BookEvent {
+	return w.events
+}
+
+// Errors gives the channel that polling errors are sent on. Errors are dropped while
+// the previous one has not been received.
+func (w *BookWatcher) Errors() <-chan error {
+	return w.errors
+}
+
+// Dropped gives the number of snapshots that were dropped for having the same timestamp
+// as the last one and for being older than it.
+func (w *BookWatcher) Dropped() (stale, outOfOrder int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.stale, w.outOfOrder
+}
+
+// Start polls in the background until Stop is called, it does nothing when it was
+// started already.
+func (w *BookWatcher) Start() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.started {
+		return
+	}
+	w.started = true
+
+	go func() {
+		defer close(w.events)
+
+		ticker := time.NewTicker(w.Interval)
+		defer ticker.Stop()
+
+		for {
+			w.poll()
+
+			select {
+			case <-w.stop:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Stop stops the polling, it is fine to call more than once.
+func (w *BookWatcher) Stop() {
+	w.once.Do(func() { close(w.stop) })
+}
+
+// poll fetches a snapshot and publishes the changes when there are any.
+func (w *BookWatcher) poll() {
+	book, err := w.fetch(w.CurrencyPair)
+	if err != nil {
+		select {
+		case w.errors <- err:
+		default:
+		}
+		return
+	}
+
+	event, ok := w.next(book)
+	if !ok {
+		return
+	}
+
+	select {
+	case w.events <- event:
+	case <-w.stop:
+	}
+}
+
+// next compares the snapshot against the last one and gives the event for it, false when
+// there is nothing to publish.
+func (w *BookWatcher) next(book *Orderbook) (BookEvent, bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.last != nil {
+		switch {
+		case book.Timestamp == w.last.Timestamp:
+			w.stale++
+			return BookEvent{}, false
+		case book.Timestamp < w.last.Timestamp:
+			w.outOfOrder++
+			return BookEvent{}, false
+		}
+	}
+
+	changes := DiffOrderbooks(w.last, book)
+	w.last = book
+	if len(changes) == 0 {
+		return BookEvent{}, false
+	}
+
+	w.seq++
+	return BookEvent{
+		Seq:          w.seq,
+		CurrencyPair: w.CurrencyPair,
+		Timestamp:    book.Timestamp,
+		Changes:      changes,
+		Book:         book,
+	}, true
+}
@@ -0,0 +1,69 @@
+package korbit
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDiffOrderbooks(t *testing.T) {
+	prev := &Orderbook{
+		Bids: []OrderbookOrder{{Price: 100, Qty: 1}, {Price: 99, Qty: 2}},
+		Asks: []OrderbookOrder{{Price: 101, Qty: 1}},
+	}
+	next := &Orderbook{
+		Bids: []OrderbookOrder{{Price: 100, Qty: 1.5}},
+		Asks: []OrderbookOrder{{Price: 101, Qty: 1}, {Price: 102, Qty: 3}},
+	}
+
+	want := []LevelChange{
+		{Side: Bid, Kind: LevelChanged, Price: 100, OldQty: 1, NewQty: 1.5},
+		{Side: Bid, Kind: LevelRemoved, Price: 99, OldQty: 2},
+		{Side: Ask, Kind: LevelAdded, Price: 102, NewQty: 3},
+	}
+
+	got := DiffOrderbooks(prev, next)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestBookWatcher(t *testing.T) {
+	snapshots := []*Orderbook{
+		{Timestamp: 2, Bids: []OrderbookOrder{{Price: 100, Qty: 1}}},
+		{Timestamp: 2, Bids: []OrderbookOrder{{Price: 100, Qty: 1}}},
+		{Timestamp: 1, Bids: []OrderbookOrder{{Price: 100, Qty: 5}}},
+		{Timestamp: 3, Bids: []OrderbookOrder{{Price: 100, Qty: 2}}},
+	}
+
+	w := NewBookWatcher(NewKorbitAPI("", "", "", ""), BTCKRW, time.Millisecond)
+	w.fetch = func(pair string) (*Orderbook, error) {
+		if len(snapshots) == 0 {
+			return &Orderbook{Timestamp: 3, Bids: []OrderbookOrder{{Price: 100, Qty: 2}}}, nil
+		}
+		book := snapshots[0]
+		snapshots = snapshots[1:]
+		return book, nil
+	}
+	w.Start()
+	w.Start() // starting again must not poll twice or close the events twice
+
+	first := <-w.Events()
+	second := <-w.Events()
+	w.Stop()
+
+	if first.Seq != 1 || len(first.Changes) != 1 || first.Changes[0].Kind != LevelAdded {
+		t.Errorf("unexpected first event: %+v", first)
+	}
+	if second.Seq != 2 || second.Timestamp != 3 || second.Changes[0].OldQty != 1 {
+		t.Errorf("unexpected second event: %+v", second)
+	}
+
+	for range w.Events() {
+	}
+
+	stale, outOfOrder := w.Dropped()
+	if stale < 1 || outOfOrder != 1 {
+		t.Errorf("dropped %d stale and %d out of order", stale, outOfOrder)
+	}
+}
@@ -0,0 +1,270 @@
+package korbit
+
+import (
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// MinCandleInterval and MaxCandleInterval are the bounds of the candle intervals.
+const (
+	MinCandleInterval = time.Second
+	MaxCandleInterval = 24 * time.Hour
+)
+
+// Candle is an OHLCV bar built from public trades. Volume is in the coin and Notional is
+// the KRW that was traded.
+type Candle struct {
+	Start    time.Time
+	Interval time.Duration
+	Open     int64
+	High     int64
+	Low      int64
+	Close    int64
+	Volume   float64
+	Notional float64
+	Trades   int
+	Closed   bool
+}
+
+// End gives the time at which the bar closes.
+func (c *Candle) End() time.Time {
+	return c.Start.Add(c.Interval)
+}
+
+// CandleBuilder aggregates trades into candles of a fixed interval. Bars are aligned to
+// the unix epoch and a bar closes when a trade for a later bar arrives or when Flush is
+// called after its end.
+type CandleBuilder struct {
+	Interval time.Duration
+	FillGaps bool // emit flat bars without volume for intervals that had no trades
+
+	Late       int // trades dropped because their bar was already closed
+	Duplicates int // trades dropped because they were already seen in the bar
+
+	current    *Candle
+	firstTrade time.Time       // time of the trade that set the open of the current bar
+	lastTrade  time.Time       // time of the trade that set the close of the current bar
+	seen       map[string]bool // ids of the trades in the current bar
+	lastClose  int64           // close of the last closed bar
+	next       time.Time       // start of the first bar that is not closed yet
+
+	stop chan struct{}
+	once sync.Once
+}
+
+// NewCandleBuilder returns a builder for the interval which must be between a second and
+// a day. Gaps are filled by default.
+func NewCandleBuilder(interval time.Duration) (*CandleBuilder, error) {
+	if interval < MinCandleInterval || interval > MaxCandleInterval {
+		return nil, errors.Errorf("candle interval %s must be between %s and %s",
+			interval, MinCandleInterval, MaxCandleInterval)
+	}
+
+	return &CandleBuilder{Interval: interval, FillGaps: true, stop: make(chan struct{})}, nil
+}
+
+// tradeID tells the trades of a bar apart, by tid or by key for the trades from the
+// websocket which have no tid.
+func tradeID(t *Trade) string {
+	if t.TID != 0 {
+		return strconv.FormatInt(t.TID, 10)
+	}
+	return t.Key()
+}
+
+// Current gives the bar that is still in progress, false if there is none yet.
+func (b *CandleBuilder) Current() (Candle, bool) {
+	if b.current == nil {
+		return Candle{}, false
+	}
+	return *b.current, true
+}
+
+// AddTrades adds trades in time order, which means the newest first slices from
+// GetTrades are fine to pass in directly, and returns the bars that were closed.
+func (b *CandleBuilder) AddTrades(trades []Trade) []Candle {
+	sorted := make([]Trade, len(trades))
+	copy(sorted, trades)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].Timestamp != sorted[j].Timestamp {
+			return sorted[i].Timestamp < sorted[j].Timestamp
+		}
+		return sorted[i].TID < sorted[j].TID
+	})
+
+	var closed []Candle
+	for _, t := range sorted {
+		closed = append(closed, b.Add(t)...)
+	}
+	return closed
+}
+
+// Add adds a single trade and returns the bars that it closed. Trades can arrive out of
+// order as long as their bar is still open.
+func (b *CandleBuilder) Add(t Trade) []Candle {
+	at := t.Time()
+	start := b.barStart(at)
+
+	var closed []Candle
+	switch {
+	case b.current == nil && start.Before(b.next),
+		b.current != nil && start.Before(b.current.Start):
+		b.Late++
+		return nil
+	case b.current != nil && start.Equal(b.current.Start) && b.seen[tradeID(&t)]:
+		b.Duplicates++
+		return nil
+	case b.current == nil:
+		closed = b.fillUntil(start)
+	case !start.Equal(b.current.Start):
+		closed = b.closeUntil(start)
+	}
+
+	if b.current == nil {
+		b.current = &Candle{
+			Start:    start,
+			Interval: b.Interval,
+			Open:     t.Price,
+			High:     t.Price,
+			Low:      t.Price,
+		}
+		b.firstTrade = at
+		b.seen = map[string]bool{}
+	}
+	b.seen[tradeID(&t)] = true
+
+	c := b.current
+	if t.Price > c.High {
+		c.High = t.Price
+	}
+	if t.Price < c.Low {
+		c.Low = t.Price
+	}
+	// trades can come in out of order within a bar, only the earliest one sets the open
+	// and the latest one the close.
+	if at.Before(b.firstTrade) {
+		c.Open = t.Price
+		b.firstTrade = at
+	}
+	if !at.Before(b.lastTrade) {
+		c.Close = t.Price
+		b.lastTrade = at
+	}
+	c.Volume += t.Amount
+	c.Notional += t.Amount * float64(t.Price)
+	c.Trades++
+
+	return closed
+}
+
+// Flush closes the bar in progress if now is past its end and gives it back together
+// with the flat bars up to now when gaps are filled. It is for closing bars in quiet
+// markets where no new trade arrives to do it.
+func (b *CandleBuilder) Flush(now time.Time) []Candle {
+	if b.current == nil || now.Before(b.current.End()) {
+		return nil
+	}
+	return b.closeUntil(b.barStart(now))
+}
+
+// barStart gives the start of the bar that t falls in, time.Truncate is not used because
+// it aligns to the zero time rather than the unix epoch.
+func (b *CandleBuilder) barStart(t time.Time) time.Time {
+	ns := t.UnixNano()
+	return time.Unix(0, ns-ns%int64(b.Interval))
+}
+
+// closeUntil closes the current bar and fills the gap up to the bar starting at start.
+func (b *CandleBuilder) closeUntil(start time.Time) []Candle {
+	prev := *b.current
+	prev.Closed = true
+
+	b.current = nil
+	b.lastTrade = time.Time{}
+	b.lastClose = prev.Close
+	b.next = prev.End()
+
+	return append([]Candle{prev}, b.fillUntil(start)...)
+}
+
+// fillUntil gives the flat bars between the last closed bar and the bar starting at
+// start when gaps are filled.
+func (b *CandleBuilder) fillUntil(start time.Time) []Candle {
+	var flat []Candle
+	if b.FillGaps && !b.next.IsZero() {
+		for s := b.next; s.Before(start); s = s.Add(b.Interval) {
+			flat = append(flat, Candle{
+				Start:    s,
+				Interval: b.Interval,
+				Open:     b.lastClose,
+				High:     b.lastClose,
+				Low:      b.lastClose,
+				Close:    b.lastClose,
+				Closed:   true,
+			})
+		}
+	}
+
+	if start.After(b.next) {
+		b.next = start
+	}
+	return flat
+}
+
+// Run builds candles from the trades on the channel until it is closed or Stop is
+// called. Every closed bar is sent followed by the bar in progress after each trade, and
+// bars are flushed on the wall clock so they close even when no trades come in.
+func (b *CandleBuilder) Run(trades <-chan Trade, out chan<- Candle) {
+	defer close(out)
+
+	tick := b.Interval
+	if tick > time.Second {
+		tick = time.Second
+	}
+	ticker := time.NewTicker(tick)
+	defer ticker.Stop()
+
+	// send gives up on the bar when the builder is stopped so that a consumer that no
+	// longer reads does not keep Run blocked.
+	send := func(c Candle) bool {
+		select {
+		case out <- c:
+			return true
+		case <-b.stop:
+			return false
+		}
+	}
+
+	for {
+		var candles []Candle
+		select {
+		case <-b.stop:
+			return
+		case t, ok := <-trades:
+			if !ok {
+				return
+			}
+			candles = b.Add(t)
+			if c, ok := b.Current(); ok {
+				candles = append(candles, c)
+			}
+		case now := <-ticker.C:
+			candles = b.Flush(now)
+		}
+
+		for _, c := range candles {
+			if !send(c) {
+				return
+			}
+		}
+	}
+}
+
+// Stop makes Run return, it is fine to call more than once.
+func (b *CandleBuilder) Stop() {
+	b.once.Do(func() { close(b.stop) })
+}
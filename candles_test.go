@@ -0,0 +1,129 @@
+package korbit
+
+import (
+	"testing"
+	"time"
+)
+
+func trade(tid int64, second int, price int64, amount float64) Trade {
+	return Trade{
+		Timestamp: int64(1500000000+second) * 1000,
+		TID:       tid,
+		Price:     price,
+		Amount:    amount,
+	}
+}
+
+func TestCandleBuilder(t *testing.T) {
+	b, err := NewCandleBuilder(10 * time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// newest first like GetTrades, with a gap of one bar between 1500000010 and 1500000030.
+	closed := b.AddTrades([]Trade{
+		trade(5, 31, 110, 1),
+		trade(4, 12, 90, 2),
+		trade(3, 3, 120, 1),
+		trade(2, 1, 80, 1),
+		trade(1, 0, 100, 1),
+	})
+
+	if len(closed) != 3 {
+		t.Fatalf("got %d closed bars, want 3", len(closed))
+	}
+
+	first := closed[0]
+	if first.Open != 100 || first.High != 120 || first.Low != 80 || first.Close != 120 ||
+		first.Volume != 3 || first.Trades != 3 || !first.Closed {
+		t.Errorf("unexpected first bar: %+v", first)
+	}
+
+	gap := closed[2]
+	if gap.Start.Unix() != 1500000020 || gap.Open != 90 || gap.Close != 90 || gap.Volume != 0 {
+		t.Errorf("unexpected gap bar: %+v", gap)
+	}
+
+	current, ok := b.Current()
+	if !ok || current.Closed || current.Close != 110 || current.Start.Unix() != 1500000030 {
+		t.Errorf("unexpected bar in progress: %+v", current)
+	}
+
+	// a trade for a bar that was already closed and one that was already seen.
+	b.Add(trade(6, 15, 1000, 1))
+	b.Add(trade(5, 31, 110, 1))
+	if b.Late != 1 || b.Duplicates != 1 {
+		t.Errorf("got %d late and %d duplicates, want 1 and 1", b.Late, b.Duplicates)
+	}
+
+	closed = b.Flush(time.Unix(1500000055, 0))
+	if len(closed) != 2 || closed[1].Start.Unix() != 1500000040 {
+		t.Errorf("unexpected flushed bars: %+v", closed)
+	}
+
+	closed = b.Add(trade(7, 75, 130, 1))
+	if len(closed) != 2 || closed[0].Start.Unix() != 1500000050 {
+		t.Errorf("gap after flush not filled: %+v", closed)
+	}
+}
+
+func TestCandleBuilderOutOfOrderClose(t *testing.T) {
+	b, err := NewCandleBuilder(time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b.Add(Trade{Timestamp: 1500000005000, Price: 100, Amount: 1})
+	b.Add(Trade{Timestamp: 1500000002000, Price: 90, Amount: 1})
+	b.Add(Trade{Timestamp: 1500000005000, Price: 100, Amount: 1}) // websocket repeat without a tid
+
+	c, _ := b.Current()
+	if c.Close != 100 || c.Low != 90 {
+		t.Errorf("late trade within the bar changed the close: %+v", c)
+	}
+
+	// a late trade with a lower tid still belongs to the bar and becomes its open.
+	b.Add(Trade{Timestamp: 1500000010000, TID: 11, Price: 105, Amount: 1})
+	b.Add(Trade{Timestamp: 1500000001000, TID: 10, Price: 95, Amount: 2})
+	b.Add(Trade{Timestamp: 1500000001000, TID: 10, Price: 95, Amount: 2})
+
+	c, _ = b.Current()
+	if c.Open != 95 || c.Close != 105 || c.Volume != 5 || c.Trades != 4 || b.Duplicates != 2 {
+		t.Errorf("late trade not placed in its bar: %+v, %d duplicates", c, b.Duplicates)
+	}
+
+	_, err = NewCandleBuilder(time.Millisecond)
+	if err == nil {
+		t.Error("expected error for interval below a second")
+	}
+}
+
+func TestCandleBuilderRunStop(t *testing.T) {
+	b, err := NewCandleBuilder(time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	trades := make(chan Trade, 1)
+	out := make(chan Candle)
+	done := make(chan struct{})
+	go func() {
+		b.Run(trades, out)
+		close(done)
+	}()
+
+	// nothing reads the bar that the trade makes.
+	trades <- trade(1, 0, 100, 1)
+	time.Sleep(10 * time.Millisecond)
+	b.Stop()
+	b.Stop()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run did not return after Stop")
+	}
+	if _, ok := <-out; ok {
+		t.Error("out was not closed")
+	}
+}
@@ -0,0 +1,455 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	korbit "github.com/deltaskelta/korbit-go"
+	"github.com/pkg/errors"
+)
+
+// dryRun is the status printed in place of the response when nothing was sent.
+const dryRun = "dry-run"
+
+func tickerCmd(c *cli, args []string) error {
+	fs := c.flags("ticker")
+	pair := fs.String("pair", "", "currency pair, every pair when empty")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	var tickers []*korbit.Prices
+	if *pair != "" {
+		p, err := c.public().GetPrices(*pair)
+		if err != nil {
+			return err
+		}
+		p.CurrencyPair = *pair
+		tickers = append(tickers, p)
+	} else {
+		all, err := c.public().GetAllTickers()
+		if err != nil {
+			return err
+		}
+		for pair, p := range all {
+			p.CurrencyPair = pair
+			tickers = append(tickers, p)
+		}
+		sort.Slice(tickers, func(i, j int) bool {
+			return tickers[i].CurrencyPair < tickers[j].CurrencyPair
+		})
+	}
+
+	r := newResult(tickers, "pair", "last", "bid", "ask", "low", "high", "volume", "change", "time")
+	for _, p := range tickers {
+		r.add(p.CurrencyPair, formatInt(p.Last), formatInt(p.Bid), formatInt(p.Ask),
+			formatInt(p.Low), formatInt(p.High), formatFloat(p.Volume),
+			formatFloat(p.ChangePercent)+"%", formatMillis(p.TimestampMillis))
+	}
+	return c.print(r)
+}
+
+func bookCmd(c *cli, args []string) error {
+	fs := c.flags("book")
+	pair := fs.String("pair", korbit.BTCKRW, "currency pair")
+	depth := fs.Int("depth", 10, "price levels shown on each side")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	book, err := c.public().GetOrderbook(*pair)
+	if err != nil {
+		return err
+	}
+	book.CurrencyPair = *pair
+	if *depth > 0 {
+		if len(book.Asks) > *depth {
+			book.Asks = book.Asks[:*depth]
+		}
+		if len(book.Bids) > *depth {
+			book.Bids = book.Bids[:*depth]
+		}
+	}
+
+	// asks are shown highest first so the spread is in the middle of the table
+	r := newResult(book, "side", "price", "qty", "orders")
+	for i := len(book.Asks) - 1; i >= 0; i-- {
+		a := book.Asks[i]
+		r.add(korbit.Ask, formatInt(a.Price), formatFloat(a.Qty), formatInt(a.Orders))
+	}
+	for _, b := range book.Bids {
+		r.add(korbit.Bid, formatInt(b.Price), formatFloat(b.Qty), formatInt(b.Orders))
+	}
+	return c.print(r)
+}
+
+func balancesCmd(c *cli, args []string) error {
+	fs := c.flags("balances")
+	all := fs.Bool("all", false, "also show the currencies with nothing in them")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	k, err := c.login()
+	if err != nil {
+		return err
+	}
+	balances, err := k.GetBalances()
+	if err != nil {
+		return err
+	}
+
+	var currencies []string
+	for cur, b := range balances {
+		if !*all && b.Available == 0 && b.TradeInuse == 0 && b.WithdrawalInUse == 0 {
+			delete(balances, cur)
+			continue
+		}
+		currencies = append(currencies, cur)
+	}
+	sort.Strings(currencies)
+
+	r := newResult(balances, "currency", "available", "trade_in_use", "withdrawal_in_use")
+	for _, cur := range currencies {
+		b := balances[cur]
+		r.add(cur, formatFloat(b.Available), formatFloat(b.TradeInuse), formatFloat(b.WithdrawalInUse))
+	}
+	return c.print(r)
+}
+
+func ordersCmd(c *cli, args []string) error {
+	if len(args) == 0 || args[0] != "list" {
+		return errors.New("usage: korbit orders list [-pair PAIR]")
+	}
+
+	fs := c.flags("orders list")
+	pair := fs.String("pair", korbit.BTCKRW, "currency pair")
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	k, err := c.login()
+	if err != nil {
+		return err
+	}
+	orders, err := k.ListOpenOrders(*pair)
+	if err != nil {
+		return err
+	}
+
+	r := newResult(orders, "id", "type", "price", "total", "open", "time")
+	for _, o := range *orders {
+		r.add(formatInt(o.ID), o.Type, formatFloat(o.Price.Value), formatFloat(o.Total.Value),
+			formatFloat(o.Open.Value), formatMillis(o.Timestamp))
+	}
+	return c.print(r)
+}
+
+func buyCmd(c *cli, args []string) error {
+	return orderCmd(c, korbit.Buy, args)
+}
+
+func sellCmd(c *cli, args []string) error {
+	return orderCmd(c, korbit.Sell, args)
+}
+
+// orderCmd places a buy or sell order. Market buys are given in KRW with -krw, everything
+// else in coin with -qty.
+func orderCmd(c *cli, side string, args []string) error {
+	fs := c.flags(side)
+	pair := fs.String("pair", korbit.BTCKRW, "currency pair")
+	typ := fs.String("type", korbit.Limit, "order type, limit or market")
+	price := fs.Int64("price", 0, "limit price in KRW")
+	qty := fs.String("qty", "", "coin amount")
+	krw := fs.String("krw", "", "KRW to spend on a market buy")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	order := &korbit.OrderArgs{CurrencyPair: *pair, Type: *typ}
+	switch {
+	case *typ == korbit.Limit:
+		prec, err := korbit.PrecisionFor(*pair)
+		if err != nil {
+			return err
+		}
+		if *price <= 0 || *price%prec.PriceTick != 0 {
+			return errors.Errorf("price must be a positive multiple of %d for %s", prec.PriceTick, *pair)
+		}
+		order.Price = *price
+		order.CoinAmount = *qty
+	case *typ == korbit.Market && side == korbit.Buy:
+		order.FiatAmount = *krw
+	case *typ == korbit.Market:
+		order.CoinAmount = *qty
+	default:
+		return errors.Errorf("unknown order type: %s", *typ)
+	}
+
+	if order.CoinAmount == "" && order.FiatAmount == "" {
+		if *typ == korbit.Market && side == korbit.Buy {
+			return errors.New("-krw is needed for a market buy")
+		}
+		return errors.New("-qty is needed")
+	}
+
+	resp := &korbit.OrderResponse{Status: dryRun, CurrencyPair: order.CurrencyPair, Side: side,
+		Price: order.Price, Type: order.Type}
+	if !c.dryRun {
+		k, err := c.login()
+		if err != nil {
+			return err
+		}
+		place := k.Buy
+		if side == korbit.Sell {
+			place = k.Sell
+		}
+		resp, err = place(order)
+		if err != nil {
+			return err
+		}
+	}
+
+	amount := order.CoinAmount
+	if amount == "" {
+		amount = order.FiatAmount + " krw"
+	}
+	r := newResult(resp, "id", "status", "pair", "side", "type", "price", "amount")
+	r.add(formatInt(resp.OrderID), resp.Status, order.CurrencyPair, side, order.Type,
+		formatInt(order.Price), amount)
+	return c.print(r)
+}
+
+func cancelCmd(c *cli, args []string) error {
+	fs := c.flags("cancel")
+	pair := fs.String("pair", korbit.BTCKRW, "currency pair")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		return errors.New("no order ids to cancel")
+	}
+
+	var ids []int64
+	for _, arg := range fs.Args() {
+		id, err := strconv.ParseInt(arg, 10, 64)
+		if err != nil {
+			return errors.Errorf("bad order id: %s", arg)
+		}
+		ids = append(ids, id)
+	}
+
+	var resps []korbit.CancelOrderResp
+	if c.dryRun {
+		for _, id := range ids {
+			resps = append(resps, korbit.CancelOrderResp{OrderID: id, Status: dryRun, CurrencyPair: *pair})
+		}
+	} else {
+		k, err := c.login()
+		if err != nil {
+			return err
+		}
+		resps, err = k.CancelOpenOrders(ids, *pair)
+		if err != nil {
+			return err
+		}
+	}
+
+	r := newResult(resps, "id", "status", "pair")
+	for _, resp := range resps {
+		r.add(formatInt(resp.OrderID), resp.Status, resp.CurrencyPair)
+	}
+	return c.print(r)
+}
+
+func historyCmd(c *cli, args []string) error {
+	fs := c.flags("history")
+	pair := fs.String("pair", korbit.BTCKRW, "currency pair, not used for fiats")
+	category := fs.String("category", korbit.Fills, "fills, fiats or coins")
+	limit := fs.Int("limit", 20, "entries to fetch")
+	offset := fs.Int("offset", 0, "entries to skip")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	k, err := c.login()
+	if err != nil {
+		return err
+	}
+	off, lim := strconv.Itoa(*offset), strconv.Itoa(*limit)
+
+	var r *result
+	switch *category {
+	case korbit.Fills:
+		fills, err := k.GetFills(*pair, off, lim, "")
+		if err != nil {
+			return err
+		}
+		r = newResult(fills, "id", "type", "price", "amount", "krw", "fee", "order", "time")
+		for _, f := range fills {
+			d := f.FillsDetail
+			r.add(formatInt(f.ID), f.Type, formatFloat(d.Price.Value), formatFloat(d.Amount.Value),
+				formatFloat(d.NativeAmount.Value), currency(f.Fee), formatInt(d.OrderID),
+				formatMillis(f.Timestamp))
+		}
+
+	case korbit.Fiats:
+		transfers, err := k.GetFiatTransfers(off, lim)
+		if err != nil {
+			return err
+		}
+		r = newResult(transfers, "id", "type", "amount", "fee", "bank", "account", "status", "time")
+		for _, t := range transfers {
+			d := t.FiatsDetail
+			r.add(formatInt(t.ID), t.Type, formatFloat(d.Amount.Value), currency(t.Fee), d.Bank,
+				d.Account, d.Status, formatMillis(t.Timestamp))
+		}
+
+	case korbit.Coins:
+		transfers, err := k.GetCoinTransfers(*pair, off, lim)
+		if err != nil {
+			return err
+		}
+		r = newResult(transfers, "id", "type", "amount", "fee", "address", "transaction", "status", "time")
+		for _, t := range transfers {
+			d := t.CoinsDetail
+			r.add(formatInt(t.ID), t.Type, currency(d.Amount), currency(t.Fee), d.Address,
+				d.TransactionID, d.Status, formatMillis(t.Timestamp))
+		}
+
+	default:
+		return errors.Errorf("unknown category: %s", *category)
+	}
+
+	return c.print(r)
+}
+
+func withdrawCmd(c *cli, args []string) error {
+	fs := c.flags("withdraw")
+	cur := fs.String("currency", "", "krw or a coin like btc")
+	amount := fs.Float64("amount", 0, "amount to withdraw")
+	address := fs.String("address", "", "address the coin is sent to")
+	tag := fs.String("tag", "", "destination tag, only for xrp")
+	yes := fs.Bool("yes", false, "withdraw without asking for confirmation")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	*cur = strings.ToLower(*cur)
+	if *cur == "" || *amount <= 0 {
+		return errors.New("-currency and a positive -amount are needed")
+	}
+	if *cur == korbit.KRW && *amount != float64(int64(*amount)) {
+		return errors.New("krw is withdrawn in whole won")
+	}
+	if *cur != korbit.KRW && *address == "" {
+		return errors.Errorf("-address is needed to withdraw %s", *cur)
+	}
+
+	resp := &korbit.WithdrawalResponse{Status: dryRun}
+	if c.dryRun {
+		g, closeAudit, err := c.withdrawer(false)
+		if err != nil {
+			return err
+		}
+		defer closeAudit()
+
+		// krw is checked against the registered bank account, not an address.
+		attempt := korbit.WithdrawalAttempt{Currency: *cur, Amount: *amount, DestinationTag: *tag}
+		if *cur != korbit.KRW {
+			attempt.Address = *address
+		}
+		err = g.Check(attempt)
+		if err != nil {
+			return err
+		}
+	} else {
+		to := *address
+		if *cur == korbit.KRW {
+			to = "the registered bank account"
+		}
+		confirmed := !*yes && c.confirm(fmt.Sprintf("withdraw %s %s to %s?", formatFloat(*amount), *cur, to))
+		if !*yes && !confirmed {
+			return errors.New("withdrawal was not confirmed")
+		}
+
+		g, closeAudit, err := c.withdrawer(confirmed)
+		if err != nil {
+			return err
+		}
+		defer closeAudit()
+
+		if *cur == korbit.KRW {
+			resp, err = g.RequestFiatWithdrawal(int64(*amount))
+		} else {
+			resp, err = g.RequestCoinWithdrawal(*cur, *amount, *address, *tag)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	r := newResult(resp, "id", "status", "currency", "amount", "address")
+	r.add(formatInt(resp.TransferID), resp.Status, *cur, formatFloat(*amount), *address)
+	return c.print(r)
+}
+
+// withdrawer gives a withdrawer that checks the policy of the config, with the daily limit
+// counting the withdrawals in the audit log of earlier runs. The confirmations the policy
+// asks for are only given when the withdrawal was confirmed at the prompt, not with -yes.
+// On a dry run the audit log is only read and the withdrawer can not send.
+func (c *cli) withdrawer(confirmed bool) (*korbit.GuardedWithdrawer, func() error, error) {
+	cfg, err := c.config()
+	if err != nil {
+		return nil, nil, err
+	}
+	if cfg.WithdrawalPolicy == nil {
+		return nil, nil, errors.New("withdrawals are refused without a withdrawal_policy in the config")
+	}
+
+	k, err := c.login()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	policy := cfg.WithdrawalPolicy.policy()
+	policy.Confirm = func(korbit.WithdrawalAttempt) bool { return confirmed }
+
+	g := korbit.NewGuardedWithdrawer(k, policy)
+	g.AuditLog = nil
+
+	path := cfg.auditLogPath(c.configPath)
+	flag := os.O_CREATE | os.O_RDWR | os.O_APPEND
+	if c.dryRun {
+		flag = os.O_RDONLY
+	}
+	audit, err := os.OpenFile(path, flag, 0600)
+	if c.dryRun && os.IsNotExist(err) {
+		return g, func() error { return nil }, nil
+	}
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "open withdrawal audit log")
+	}
+
+	if !c.dryRun {
+		g.AuditLog = audit
+	}
+	err = g.ReplayAuditLog(audit)
+	if err != nil {
+		audit.Close()
+		return nil, nil, errors.Wrapf(err, "replay %s", path)
+	}
+
+	return g, audit.Close, nil
+}
+
+// currency formats an amount with its currency, like 0.5 btc.
+func currency(c korbit.Currency) string {
+	if c.Currency == "" {
+		return formatFloat(c.Value)
+	}
+	return formatFloat(c.Value) + " " + c.Currency
+}
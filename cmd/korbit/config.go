@@ -0,0 +1,114 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+
+	korbit "github.com/deltaskelta/korbit-go"
+	"github.com/pkg/errors"
+)
+
+// config is the credentials of the account and the policy that withdrawals are checked
+// against. Withdrawals are refused when there is no policy.
+type config struct {
+	ClientID     string `json:"client_id"`
+	ClientSecret string `json:"client_secret"`
+	Username     string `json:"username"`
+	Password     string `json:"password"`
+
+	WithdrawalPolicy *withdrawalPolicy `json:"withdrawal_policy"`
+	AuditLog         string            `json:"audit_log"` // defaults to withdrawals.log next to the config
+}
+
+// withdrawalPolicy is korbit.WithdrawalPolicy as it is written in the config.
+type withdrawalPolicy struct {
+	AllowedAddresses map[string][]korbit.AllowedAddress `json:"allowed_addresses"`
+	MaxPerWithdrawal map[string]float64                 `json:"max_per_withdrawal"`
+	MaxPerDay        map[string]float64                 `json:"max_per_day"`
+	ConfirmAbove     map[string]float64                 `json:"confirm_above"`
+}
+
+func (p *withdrawalPolicy) policy() korbit.WithdrawalPolicy {
+	return korbit.WithdrawalPolicy{
+		AllowedAddresses: p.AllowedAddresses,
+		MaxPerWithdrawal: p.MaxPerWithdrawal,
+		MaxPerDay:        p.MaxPerDay,
+		ConfirmAbove:     p.ConfirmAbove,
+	}
+}
+
+// defaultConfigPath is where the config is read from when no path is given.
+func defaultConfigPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "korbit", "config.json")
+}
+
+// auditLogPath gives the path of the withdrawal audit log for the config at configPath.
+func (c config) auditLogPath(configPath string) string {
+	if c.AuditLog != "" {
+		return c.AuditLog
+	}
+	if configPath == "" {
+		configPath = defaultConfigPath()
+	}
+	return filepath.Join(filepath.Dir(configPath), "withdrawals.log")
+}
+
+// loadConfig reads the config file and then the environment, which wins. A missing file
+// is only an error when the path was given.
+func loadConfig(path string, getenv func(string) string) (config, error) {
+	var cfg config
+
+	explicit := path != ""
+	if !explicit {
+		path = defaultConfigPath()
+	}
+
+	if path != "" {
+		b, err := os.ReadFile(path)
+		switch {
+		case os.IsNotExist(err) && !explicit:
+		case err != nil:
+			return cfg, errors.Wrap(err, "read config")
+		default:
+			err = json.Unmarshal(b, &cfg)
+			if err != nil {
+				return cfg, errors.Wrapf(err, "parse config %s", path)
+			}
+		}
+	}
+
+	env := map[string]*string{
+		"KORBIT_CLIENT_ID":     &cfg.ClientID,
+		"KORBIT_CLIENT_SECRET": &cfg.ClientSecret,
+		"KORBIT_USERNAME":      &cfg.Username,
+		"KORBIT_PASSWORD":      &cfg.Password,
+	}
+	for name, field := range env {
+		if v := getenv(name); v != "" {
+			*field = v
+		}
+	}
+
+	return cfg, nil
+}
+
+// missing gives the names of the credentials that are not set.
+func (c config) missing() []string {
+	var names []string
+	for _, f := range []struct{ name, value string }{
+		{"client_id", c.ClientID},
+		{"client_secret", c.ClientSecret},
+		{"username", c.Username},
+		{"password", c.Password},
+	} {
+		if f.value == "" {
+			names = append(names, f.name)
+		}
+	}
+	return names
+}
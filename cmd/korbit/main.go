@@ -0,0 +1,177 @@
+// Command korbit is a command line tool for everyday korbit account operations.
+//
+//	korbit [flags] <command> [command flags] [args]
+//
+// The credentials are read from the config file, ~/.config/korbit/config.json unless
+// -config is given, and the KORBIT_CLIENT_ID, KORBIT_CLIENT_SECRET, KORBIT_USERNAME and
+// KORBIT_PASSWORD environment variables, which take precedence over the file. Withdrawals
+// are checked against the withdrawal_policy of the config file and refused without one.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	korbit "github.com/deltaskelta/korbit-go"
+	"github.com/pkg/errors"
+)
+
+// command runs a subcommand with the arguments after its name.
+type command struct {
+	usage string
+	run   func(c *cli, args []string) error
+}
+
+var commands = map[string]command{
+	"ticker":   {"ticker [-pair PAIR]", tickerCmd},
+	"book":     {"book [-pair PAIR] [-depth N]", bookCmd},
+	"balances": {"balances [-all]", balancesCmd},
+	"orders":   {"orders list [-pair PAIR]", ordersCmd},
+	"buy":      {"buy [-pair PAIR] [-type limit|market] [-price KRW] [-qty COIN] [-krw KRW]", buyCmd},
+	"sell":     {"sell [-pair PAIR] [-type limit|market] [-price KRW] [-qty COIN]", sellCmd},
+	"cancel":   {"cancel [-pair PAIR] ID...", cancelCmd},
+	"history":  {"history [-pair PAIR] [-category fills|fiats|coins] [-limit N] [-offset N]", historyCmd},
+	"withdraw": {"withdraw -currency CUR -amount N [-address ADDR] [-tag TAG] [-yes]", withdrawCmd},
+}
+
+// cli holds the options shared by every command.
+type cli struct {
+	in     io.Reader
+	out    io.Writer
+	errOut io.Writer
+	getenv func(string) string
+
+	output     string
+	configPath string
+	dryRun     bool
+
+	cfg *config     // set once loaded
+	api *korbit.API // set once logged in
+}
+
+func main() {
+	c := &cli{in: os.Stdin, out: os.Stdout, errOut: os.Stderr, getenv: os.Getenv}
+	err := c.run(os.Args[1:])
+	if err == flag.ErrHelp {
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "korbit:", err)
+		os.Exit(1)
+	}
+}
+
+// run parses the shared flags and runs the command that follows them.
+func (c *cli) run(args []string) error {
+	fs := c.flags("korbit")
+	fs.Usage = c.usage
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	if fs.NArg() == 0 {
+		c.usage()
+		return flag.ErrHelp
+	}
+
+	cmd, ok := commands[fs.Arg(0)]
+	if !ok {
+		c.usage()
+		return errors.Errorf("unknown command: %s", fs.Arg(0))
+	}
+	return cmd.run(c, fs.Args()[1:])
+}
+
+// flags returns a flag set with the shared flags, so they can be given before or after
+// the command.
+func (c *cli) flags(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(c.errOut)
+	if c.output == "" {
+		c.output = tableOutput
+	}
+	fs.StringVar(&c.output, "o", c.output, "output format: table, json or csv")
+	fs.StringVar(&c.configPath, "config", c.configPath, "config file with the credentials")
+	fs.BoolVar(&c.dryRun, "dry-run", c.dryRun, "print what would be sent without sending it")
+	return fs
+}
+
+func (c *cli) usage() {
+	fmt.Fprintln(c.errOut, "usage: korbit [-o table|json|csv] [-config FILE] [-dry-run] <command>")
+	fmt.Fprintln(c.errOut, "\ncommands:")
+
+	var names []string
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(c.errOut, "  korbit %s\n", commands[name].usage)
+	}
+}
+
+// public gives an api for the public endpoints, which need no login.
+func (c *cli) public() *korbit.API {
+	if c.api != nil {
+		return c.api
+	}
+	return korbit.NewKorbitAPI("", "", "", "")
+}
+
+// login gives an api that is logged in with the configured credentials.
+func (c *cli) login() (*korbit.API, error) {
+	if c.api != nil {
+		return c.api, nil
+	}
+
+	cfg, err := c.config()
+	if err != nil {
+		return nil, err
+	}
+	if missing := cfg.missing(); len(missing) > 0 {
+		return nil, errors.Errorf("missing credentials: %s", strings.Join(missing, ", "))
+	}
+
+	k := korbit.NewKorbitAPI(cfg.ClientID, cfg.ClientSecret, cfg.Username, cfg.Password)
+	err = k.Login()
+	if err != nil {
+		return nil, errors.Wrap(err, "login")
+	}
+
+	c.api = k
+	return k, nil
+}
+
+// config gives the config, which is only read once.
+func (c *cli) config() (*config, error) {
+	if c.cfg != nil {
+		return c.cfg, nil
+	}
+
+	cfg, err := loadConfig(c.configPath, c.getenv)
+	if err != nil {
+		return nil, err
+	}
+	c.cfg = &cfg
+	return c.cfg, nil
+}
+
+// confirm asks the question and reports whether it was answered with yes.
+func (c *cli) confirm(question string) bool {
+	fmt.Fprintf(c.errOut, "%s [y/N] ", question)
+
+	var answer string
+	fmt.Fscanln(c.in, &answer)
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
+
+// print writes the result in the chosen output format.
+func (c *cli) print(r *result) error {
+	return r.write(c.out, c.output)
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	korbit "github.com/deltaskelta/korbit-go"
+)
+
+func env(vars map[string]string) func(string) string {
+	return func(name string) string { return vars[name] }
+}
+
+func TestLoadConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	err := os.WriteFile(path, []byte(`{"client_id":"file-id","client_secret":"file-secret",
+		"username":"file-user","password":"file-pass"}`), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := loadConfig(path, env(map[string]string{"KORBIT_PASSWORD": "env-pass"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.ClientID != "file-id" || cfg.Username != "file-user" || cfg.Password != "env-pass" {
+		t.Errorf("environment should override the file: %+v", cfg)
+	}
+	if len(cfg.missing()) != 0 {
+		t.Errorf("unexpected missing credentials: %v", cfg.missing())
+	}
+
+	_, err = loadConfig(filepath.Join(t.TempDir(), "nope.json"), env(nil))
+	if err == nil {
+		t.Error("expected an error for a config file that is not there")
+	}
+}
+
+func TestResultWrite(t *testing.T) {
+	r := newResult([]int{1, 2}, "id", "name")
+	r.add("1", "one, two")
+	r.add("2", "three")
+
+	var buf bytes.Buffer
+	if err := r.write(&buf, csvOutput); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != "id,name\n1,\"one, two\"\n2,three\n" {
+		t.Errorf("unexpected csv: %q", buf.String())
+	}
+
+	buf.Reset()
+	if err := r.write(&buf, tableOutput); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 3 || !strings.HasPrefix(lines[0], "ID  NAME") {
+		t.Errorf("unexpected table: %q", buf.String())
+	}
+
+	buf.Reset()
+	if err := r.write(&buf, jsonOutput); err != nil {
+		t.Fatal(err)
+	}
+	var got []int
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil || len(got) != 2 {
+		t.Errorf("unexpected json: %q", buf.String())
+	}
+
+	if err := r.write(&buf, "xml"); err == nil {
+		t.Error("expected an error for an unknown format")
+	}
+}
+
+func TestDryRunOrder(t *testing.T) {
+	var out, errOut bytes.Buffer
+	c := &cli{out: &out, errOut: &errOut, getenv: env(nil)}
+
+	err := c.run([]string{"-dry-run", "buy", "-o", "csv", "-price", "3000500", "-qty", "0.1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.api != nil {
+		t.Error("a dry run should not log in")
+	}
+	want := "id,status,pair,side,type,price,amount\n0,dry-run,btc_krw,buy,limit,3000500,0.1\n"
+	if out.String() != want {
+		t.Errorf("unexpected output: %q", out.String())
+	}
+
+	err = c.run([]string{"-dry-run", "buy", "-price", "3000100", "-qty", "0.1"})
+	if err == nil {
+		t.Error("expected an error for a price off the tick")
+	}
+}
+
+func TestWithdraw(t *testing.T) {
+	var withdrawals []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/login":
+			fmt.Fprint(w, `{"access_token": "token", "token_type": "Bearer", "expires_in": 3600}`)
+		case "/withdraw":
+			r.ParseForm()
+			withdrawals = append(withdrawals, r.Form.Get("address"))
+			fmt.Fprintf(w, `{"transferId": "%d", "status": "success"}`, len(withdrawals))
+		default:
+			t.Errorf("unexpected request: %s", r.URL)
+		}
+	}))
+	defer srv.Close()
+
+	oldLogin, oldWithdrawal := korbit.LoginURL, korbit.BtcWithdrawal
+	korbit.LoginURL, korbit.BtcWithdrawal = srv.URL+"/login", srv.URL+"/withdraw"
+	defer func() { korbit.LoginURL, korbit.BtcWithdrawal = oldLogin, oldWithdrawal }()
+
+	dir := t.TempDir()
+	creds := `"client_id": "id", "client_secret": "secret", "username": "user", "password": "pass"`
+	writeConfig := func(policy string) string {
+		path := filepath.Join(dir, "config.json")
+		err := os.WriteFile(path, []byte("{"+creds+policy+"}"), 0600)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	withdraw := func(config, answer string, args ...string) error {
+		c := &cli{in: strings.NewReader(answer), out: &bytes.Buffer{}, errOut: &bytes.Buffer{}, getenv: env(nil)}
+		global := []string{"-config", config}
+		if answer == dryRun {
+			global = append(global, "-dry-run")
+		}
+		return c.run(append(append(global, "withdraw", "-currency", "btc"), args...))
+	}
+
+	config := writeConfig("")
+	for _, answer := range []string{"", dryRun} {
+		if err := withdraw(config, answer, "-amount", "0.1", "-address", "1Cold", "-yes"); err == nil ||
+			!strings.Contains(err.Error(), "withdrawal_policy") {
+			t.Errorf("got %v, want withdrawals refused without a policy", err)
+		}
+	}
+
+	config = writeConfig(`, "withdrawal_policy": {"allowed_addresses": {"btc": [{"address": "1Cold"}]},
+		"max_per_day": {"btc": 1}, "confirm_above": {"btc": 0.5}}`)
+
+	// a dry run checks the policy without sending or writing the audit log.
+	if err := withdraw(config, dryRun, "-amount", "0.1", "-address", "1Hot"); err == nil {
+		t.Error("dry run to an address that is not allowed was not refused")
+	}
+	if err := withdraw(config, dryRun, "-amount", "0.1", "-address", "1Cold"); err != nil {
+		t.Error(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "withdrawals.log")); !os.IsNotExist(err) {
+		t.Errorf("dry run wrote the audit log: %v", err)
+	}
+
+	cases := []struct {
+		answer string
+		args   []string
+		sent   bool
+	}{
+		{"n\n", []string{"-amount", "0.1", "-address", "1Cold"}, false},
+		{"y\n", []string{"-amount", "0.1", "-address", "1Cold"}, true},
+		{"", []string{"-amount", "0.1", "-address", "1Hot", "-yes"}, false},  // not allowed
+		{"", []string{"-amount", "0.6", "-address", "1Cold", "-yes"}, false}, // needs the prompt
+		{"y\n", []string{"-amount", "0.6", "-address", "1Cold"}, true},
+		{"y\n", []string{"-amount", "0.4", "-address", "1Cold"}, false}, // over the day in the log
+	}
+	for i, tc := range cases {
+		before := len(withdrawals)
+		err := withdraw(config, tc.answer, tc.args...)
+		if sent := len(withdrawals) > before; sent != tc.sent || tc.sent != (err == nil) {
+			t.Errorf("withdrawal %d: sent %v with %v", i, sent, err)
+		}
+	}
+
+	if err := withdraw(config, dryRun, "-amount", "0.4", "-address", "1Cold"); err == nil {
+		t.Error("dry run over the daily limit in the audit log was not refused")
+	}
+
+	b, err := os.ReadFile(filepath.Join(dir, "withdrawals.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Count(string(b), "\n"); lines != 2*2+3 {
+		t.Errorf("got %d audit lines, want 7", lines)
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// tableOutput and the other formats here are the values of the -o flag.
+const (
+	tableOutput = "table"
+	jsonOutput  = "json"
+	csvOutput   = "csv"
+)
+
+// result is the output of a command. JSON output is the value as it came from the api,
+// table and CSV output are the rows.
+type result struct {
+	value  interface{}
+	header []string
+	rows   [][]string
+}
+
+func newResult(value interface{}, header ...string) *result {
+	return &result{value: value, header: header}
+}
+
+func (r *result) add(cells ...string) {
+	r.rows = append(r.rows, cells)
+}
+
+// write writes the result in the format.
+func (r *result) write(w io.Writer, format string) error {
+	switch format {
+	case jsonOutput:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return errors.Wrap(enc.Encode(r.value), "write json")
+
+	case csvOutput:
+		cw := csv.NewWriter(w)
+		err := cw.Write(r.header)
+		if err != nil {
+			return errors.Wrap(err, "write csv")
+		}
+		err = cw.WriteAll(r.rows)
+		return errors.Wrap(err, "write csv")
+
+	case tableOutput:
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, strings.ToUpper(strings.Join(r.header, "\t")))
+		for _, row := range r.rows {
+			fmt.Fprintln(tw, strings.Join(row, "\t"))
+		}
+		return errors.Wrap(tw.Flush(), "write table")
+	}
+
+	return errors.Errorf("unknown output format: %s", format)
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+func formatInt(v int64) string {
+	return strconv.FormatInt(v, 10)
+}
+
+// formatMillis formats a korbit millisecond timestamp in UTC.
+func formatMillis(ms int64) string {
+	if ms == 0 {
+		return ""
+	}
+	return time.Unix(0, ms*int64(time.Millisecond)).UTC().Format(time.RFC3339)
+}
@@ -0,0 +1,145 @@
+package korbit
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// EventRecord is how a MarketEvent is written to a file, one JSON object per line. Only
+// the field matching Type is set.
+type EventRecord struct {
+	Type         string     `json:"type"`
+	CurrencyPair string     `json:"currency_pair"`
+	Ticker       *Prices    `json:"ticker,omitempty"`
+	Orderbook    *Orderbook `json:"orderbook,omitempty"`
+	Trade        *Trade     `json:"trade,omitempty"`
+}
+
+// EventSource gives market events one at a time, in time order. Next returns io.EOF when
+// there are no more events.
+type EventSource interface {
+	Next() (MarketEvent, error)
+}
+
+// EventWriter writes market events as lines of JSON.
+type EventWriter struct {
+	enc *json.Encoder
+}
+
+// NewEventWriter returns a writer that writes to w.
+func NewEventWriter(w io.Writer) *EventWriter {
+	return &EventWriter{enc: json.NewEncoder(w)}
+}
+
+// Write writes the event as a single line.
+func (w *EventWriter) Write(e MarketEvent) error {
+	err := w.enc.Encode(EventRecord{
+		Type:         e.Type,
+		CurrencyPair: e.CurrencyPair,
+		Ticker:       e.Ticker,
+		Orderbook:    e.Orderbook,
+		Trade:        e.Trade,
+	})
+	if err != nil {
+		return errors.Wrap(err, "write market event")
+	}
+	return nil
+}
+
+// EventReader reads the events that an EventWriter wrote.
+type EventReader struct {
+	scanner  *bufio.Scanner
+	location *time.Location
+	line     int
+}
+
+// NewEventReader returns a reader that reads from r. Ticker times are put in UTC.
+func NewEventReader(r io.Reader) *EventReader {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024) // orderbook lines can be long
+	return &EventReader{scanner: scanner, location: time.UTC}
+}
+
+// Next reads the next event, skipping blank lines.
+func (r *EventReader) Next() (MarketEvent, error) {
+	for r.scanner.Scan() {
+		r.line++
+		b := r.scanner.Bytes()
+		if len(b) == 0 {
+			continue
+		}
+
+		var rec EventRecord
+		err := json.Unmarshal(b, &rec)
+		if err != nil {
+			return MarketEvent{}, errors.Wrapf(err, "market event on line %d", r.line)
+		}
+
+		e := MarketEvent{
+			Type:         rec.Type,
+			CurrencyPair: rec.CurrencyPair,
+			Ticker:       rec.Ticker,
+			Orderbook:    rec.Orderbook,
+			Trade:        rec.Trade,
+		}
+		if e.Ticker != nil {
+			e.Ticker.CurrencyPair = rec.CurrencyPair
+			e.Ticker.setTimestamp(r.location)
+		}
+		return e, nil
+	}
+
+	if err := r.scanner.Err(); err != nil {
+		return MarketEvent{}, errors.Wrap(err, "read market events")
+	}
+	return MarketEvent{}, io.EOF
+}
+
+// mergedEvents gives the events of several sources in time order.
+type mergedEvents struct {
+	sources []EventSource
+	heads   []*MarketEvent
+}
+
+// MergeEvents merges sources that are each in time order into a single source in time
+// order, like the files of several pairs. Events at the same time keep the order of the
+// sources.
+func MergeEvents(sources ...EventSource) EventSource {
+	return &mergedEvents{sources: sources, heads: make([]*MarketEvent, len(sources))}
+}
+
+// Next gives the earliest of the next events of the sources.
+func (m *mergedEvents) Next() (MarketEvent, error) {
+	first := -1
+	for i, s := range m.sources {
+		if s == nil {
+			continue
+		}
+		if m.heads[i] == nil {
+			e, err := s.Next()
+			if err == io.EOF {
+				m.sources[i] = nil
+				continue
+			}
+			if err != nil {
+				return MarketEvent{}, err
+			}
+			m.heads[i] = &e
+		}
+		if first < 0 || m.heads[i].Time().Before(m.heads[first].Time()) {
+			first = i
+		}
+	}
+
+	if first < 0 {
+		return MarketEvent{}, io.EOF
+	}
+
+	e := *m.heads[first]
+	m.heads[first] = nil
+	return e, nil
+}
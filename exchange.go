@@ -1,7 +1,6 @@
 package korbit
 
 import (
-	"bytes"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
@@ -244,6 +243,23 @@ type FillDetail struct {
 // explanation for the url parameters can be found at :
 // https://apidocs.korbit.co.kr/#user-:-transaction-history---order-fills,-krw/btc-deposit-and-transfer
 func (k *API) GetTransactionHistory(coin, category, offset, limit, orderID string) (*[]TransactionsResponse, error) {
+	respBytes, err := k.transactionHistory(coin, category, offset, limit, orderID)
+	if err != nil {
+		return nil, err
+	}
+
+	var retResp []TransactionsResponse
+	err = json.Unmarshal(respBytes, &retResp)
+	if err != nil {
+		return nil, errors.Wrapf(err, "get transaction history for: %s", coin)
+	}
+
+	return &retResp, nil
+}
+
+// transactionHistory gets the raw transaction history so that each category can be decoded
+// into its own type. The quoted BTC ids are unquoted so every coin decodes the same.
+func (k *API) transactionHistory(coin, category, offset, limit, orderID string) ([]byte, error) {
 	url := fmt.Sprintf("%s?", TransactionHistory)
 
 	if coin == "" {
@@ -262,7 +278,7 @@ func (k *API) GetTransactionHistory(coin, category, offset, limit, orderID strin
 		url = fmt.Sprintf("%sorder_id=%s&", url, orderID)
 	}
 
-	url = fmt.Sprintf("%scurrency_pair=%s", url, coin)
+	url = fmt.Sprintf("%scategory=%s&currency_pair=%s", url, category, coin)
 	req, err := k.NewRequest(url, "GET", nil)
 	if err != nil {
 		return nil, errors.Wrapf(err, "korbit transaction history for: %s", coin)
@@ -283,26 +299,14 @@ func (k *API) GetTransactionHistory(coin, category, offset, limit, orderID strin
 		return nil, errors.Wrap(err, "transaction history read response")
 	}
 
-	var buffer *bytes.Buffer
 	// if the coin is bitcoin then the id field is quoted, regexing to find and replace
 	// with no quotes so it will be the same across coins.
-	switch coin {
-	case BTCKRW:
-		re := regexp.MustCompile(`"id":"([0-9]+)"`)
-		new := []byte(`"id":$1`)
-		replaced := re.ReplaceAll(respBytes, new)
-		buffer = bytes.NewBuffer(replaced)
-	default:
-		buffer = bytes.NewBuffer(respBytes)
-	}
-
-	var retResp []TransactionsResponse
-	err = json.NewDecoder(buffer).Decode(&retResp)
-	if err != nil {
-		return nil, errors.Wrapf(err, "get transaction history for: %s", coin)
+	if coin == BTCKRW {
+		re := regexp.MustCompile(`"id":\s*"([0-9]+)"`)
+		respBytes = re.ReplaceAll(respBytes, []byte(`"id":$1`))
 	}
 
-	return &retResp, nil
+	return respBytes, nil
 }
 
 // TotalBuySellHistory gives the buy and sell history for a transaction response.
@@ -0,0 +1,462 @@
+package korbit
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// ExportFormat is the file format that a TransactionExporter writes.
+type ExportFormat int
+
+// CSV and NDJSON are the supported export formats.
+const (
+	CSV ExportFormat = iota
+	NDJSON
+)
+
+// Ext gives the file extension that is used for the format.
+func (f ExportFormat) Ext() string {
+	if f == NDJSON {
+		return "ndjson"
+	}
+	return "csv"
+}
+
+// exportColumns is the column schema of the exported files, new columns must only ever
+// be appended so that files written by older versions can still be resumed.
+var exportColumns = []string{
+	"timestamp",
+	"completed_at",
+	"id",
+	"category",
+	"type",
+	"fee_currency",
+	"fee_value",
+	"fill_price_currency",
+	"fill_price",
+	"fill_amount_currency",
+	"fill_amount",
+	"fill_native_amount_currency",
+	"fill_native_amount",
+	"fill_order_id",
+	"balance_krw",
+	"balance_coin",
+	"transfer_amount_currency",
+	"transfer_amount",
+	"transfer_address",
+	"transfer_transaction_id",
+	"transfer_bank",
+	"transfer_account",
+	"transfer_status",
+}
+
+// TransactionRecord is a fill or a transfer with the nested details and balances flattened
+// so that it fits in a single row. Only the columns of its category are set.
+type TransactionRecord struct {
+	Timestamp                int64   `json:"timestamp"`
+	CompletedAt              int64   `json:"completed_at"`
+	ID                       int64   `json:"id"`
+	Category                 string  `json:"category"`
+	Type                     string  `json:"type"`
+	FeeCurrency              string  `json:"fee_currency"`
+	FeeValue                 float64 `json:"fee_value"`
+	FillPriceCurrency        string  `json:"fill_price_currency"`
+	FillPrice                float64 `json:"fill_price"`
+	FillAmountCurrency       string  `json:"fill_amount_currency"`
+	FillAmount               float64 `json:"fill_amount"`
+	FillNativeAmountCurrency string  `json:"fill_native_amount_currency"`
+	FillNativeAmount         float64 `json:"fill_native_amount"`
+	FillOrderID              int64   `json:"fill_order_id"`
+	BalanceKRW               float64 `json:"balance_krw"`
+	BalanceCoin              float64 `json:"balance_coin"`
+	TransferAmountCurrency   string  `json:"transfer_amount_currency,omitempty"`
+	TransferAmount           float64 `json:"transfer_amount,omitempty"`
+	TransferAddress          string  `json:"transfer_address,omitempty"`
+	TransferTransactionID    string  `json:"transfer_transaction_id,omitempty"`
+	TransferBank             string  `json:"transfer_bank,omitempty"`
+	TransferAccount          string  `json:"transfer_account,omitempty"`
+	TransferStatus           string  `json:"transfer_status,omitempty"`
+}
+
+// NewTransactionRecord flattens a transaction of the given category.
+func NewTransactionRecord(category string, t *TransactionsResponse) *TransactionRecord {
+	r := TransactionRecord{
+		Timestamp:                t.Timestamp,
+		CompletedAt:              t.CompletedAt,
+		ID:                       t.ID,
+		Category:                 category,
+		Type:                     t.Type,
+		FeeCurrency:              t.Fee.Currency,
+		FeeValue:                 t.Fee.Value,
+		FillPriceCurrency:        t.FillsDetail.Price.Currency,
+		FillPrice:                t.FillsDetail.Price.Value,
+		FillAmountCurrency:       t.FillsDetail.Amount.Currency,
+		FillAmount:               t.FillsDetail.Amount.Value,
+		FillNativeAmountCurrency: t.FillsDetail.NativeAmount.Currency,
+		FillNativeAmount:         t.FillsDetail.NativeAmount.Value,
+		FillOrderID:              t.FillsDetail.OrderID,
+	}
+	r.setBalances(t.Balances)
+
+	return &r
+}
+
+// NewFiatTransferRecord flattens a KRW deposit or withdrawal.
+func NewFiatTransferRecord(t *FiatTransfer) *TransactionRecord {
+	r := TransactionRecord{
+		Timestamp:              t.Timestamp,
+		CompletedAt:            t.CompletedAt,
+		ID:                     t.ID,
+		Category:               Fiats,
+		Type:                   t.Type,
+		FeeCurrency:            t.Fee.Currency,
+		FeeValue:               t.Fee.Value,
+		TransferAmountCurrency: t.FiatsDetail.Amount.Currency,
+		TransferAmount:         t.FiatsDetail.Amount.Value,
+		TransferBank:           t.FiatsDetail.Bank,
+		TransferAccount:        t.FiatsDetail.Account,
+		TransferStatus:         t.FiatsDetail.Status,
+	}
+	r.setBalances(t.Balances)
+
+	return &r
+}
+
+// NewCoinTransferRecord flattens a coin deposit or withdrawal.
+func NewCoinTransferRecord(t *CoinTransfer) *TransactionRecord {
+	r := TransactionRecord{
+		Timestamp:              t.Timestamp,
+		CompletedAt:            t.CompletedAt,
+		ID:                     t.ID,
+		Category:               Coins,
+		Type:                   t.Type,
+		FeeCurrency:            t.Fee.Currency,
+		FeeValue:               t.Fee.Value,
+		TransferAmountCurrency: t.CoinsDetail.Amount.Currency,
+		TransferAmount:         t.CoinsDetail.Amount.Value,
+		TransferAddress:        t.CoinsDetail.Address,
+		TransferTransactionID:  t.CoinsDetail.TransactionID,
+		TransferStatus:         t.CoinsDetail.Status,
+	}
+	r.setBalances(t.Balances)
+
+	return &r
+}
+
+func (r *TransactionRecord) setBalances(balances []Currency) {
+	for _, b := range balances {
+		if b.Currency == KRW {
+			r.BalanceKRW = b.Value
+		} else {
+			r.BalanceCoin = b.Value
+		}
+	}
+}
+
+// Row gives the record as strings in the order of the export columns.
+func (r *TransactionRecord) Row() []string {
+	f := func(v float64) string { return strconv.FormatFloat(v, 'f', -1, 64) }
+	i := func(v int64) string { return strconv.FormatInt(v, 10) }
+
+	return []string{
+		i(r.Timestamp),
+		i(r.CompletedAt),
+		i(r.ID),
+		r.Category,
+		r.Type,
+		r.FeeCurrency,
+		f(r.FeeValue),
+		r.FillPriceCurrency,
+		f(r.FillPrice),
+		r.FillAmountCurrency,
+		f(r.FillAmount),
+		r.FillNativeAmountCurrency,
+		f(r.FillNativeAmount),
+		i(r.FillOrderID),
+		f(r.BalanceKRW),
+		f(r.BalanceCoin),
+		r.TransferAmountCurrency,
+		f(r.TransferAmount),
+		r.TransferAddress,
+		r.TransferTransactionID,
+		r.TransferBank,
+		r.TransferAccount,
+		r.TransferStatus,
+	}
+}
+
+// after reports whether the record is newer than the other one.
+func (r *TransactionRecord) after(o *TransactionRecord) bool {
+	if r.Timestamp != o.Timestamp {
+		return r.Timestamp > o.Timestamp
+	}
+	return r.ID > o.ID
+}
+
+// TransactionExporter pages through the transaction history of a currency pair and
+// appends everything that has not been exported yet to a file.
+type TransactionExporter struct {
+	API          *API
+	CurrencyPair string
+	Format       ExportFormat
+	PageSize     int
+}
+
+// NewTransactionExporter returns an exporter for the pair with a default page size.
+func NewTransactionExporter(api *API, pair string, format ExportFormat) *TransactionExporter {
+	return &TransactionExporter{
+		API:          api,
+		CurrencyPair: pair,
+		Format:       format,
+		PageSize:     100,
+	}
+}
+
+// ExportAll exports the fills, fiats and coins history into dir, one file per category
+// named PAIR_CATEGORY.EXT, and returns how many records were added to each. The KRW
+// transfers are the same for every pair so they go to fiats.EXT, which the exporters of
+// the other pairs in dir resume instead of writing them again.
+func (e *TransactionExporter) ExportAll(dir string) (map[string]int, error) {
+	added := map[string]int{}
+
+	for _, category := range []string{Fills, Fiats, Coins} {
+		name := fmt.Sprintf("%s_%s.%s", e.CurrencyPair, category, e.Format.Ext())
+		if category == Fiats {
+			name = fmt.Sprintf("%s.%s", Fiats, e.Format.Ext())
+		}
+		n, err := e.Export(category, filepath.Join(dir, name))
+		if err != nil {
+			return added, errors.Wrapf(err, "exporting %s", category)
+		}
+		added[category] = n
+	}
+
+	return added, nil
+}
+
+// Export appends the transactions of the category that are newer than the newest record
+// in path, creating the file if needed. Korbit pages from the newest transaction
+// backwards so the new records are collected first and then written oldest first.
+func (e *TransactionExporter) Export(category, path string) (int, error) {
+	if e.PageSize <= 0 {
+		return 0, errors.Errorf("export page size must be positive, got %d", e.PageSize)
+	}
+
+	last, err := e.lastRecord(path)
+	if err != nil {
+		return 0, errors.Wrapf(err, "reading %s", path)
+	}
+
+	var records []*TransactionRecord
+	for offset := 0; ; offset += e.PageSize {
+		page, err := e.page(category, offset)
+		if err != nil {
+			return 0, errors.Wrapf(err, "export page at offset %d", offset)
+		}
+
+		done := len(page) < e.PageSize
+		for _, r := range page {
+			if last != nil && !r.after(last) {
+				done = true
+				break
+			}
+			records = append(records, r)
+		}
+
+		if done {
+			break
+		}
+	}
+
+	if len(records) == 0 {
+		return 0, nil
+	}
+
+	header := true
+	if info, err := os.Stat(path); err == nil && info.Size() > 0 {
+		header = false
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return 0, errors.Wrapf(err, "opening %s", path)
+	}
+	defer file.Close()
+
+	for i, j := 0, len(records)-1; i < j; i, j = i+1, j-1 {
+		records[i], records[j] = records[j], records[i]
+	}
+
+	switch e.Format {
+	case NDJSON:
+		err = writeNDJSON(file, records)
+	default:
+		err = writeCSV(file, records, header)
+	}
+	if err != nil {
+		return 0, errors.Wrapf(err, "writing %s", path)
+	}
+
+	return len(records), nil
+}
+
+// page gets a page of the category with each transaction decoded into its own type.
+func (e *TransactionExporter) page(category string, offset int) ([]*TransactionRecord, error) {
+	off, limit := strconv.Itoa(offset), strconv.Itoa(e.PageSize)
+
+	var records []*TransactionRecord
+	switch category {
+	case Fills:
+		fills, err := e.API.GetFills(e.CurrencyPair, off, limit, "")
+		if err != nil {
+			return nil, err
+		}
+		for i := range fills {
+			records = append(records, NewTransactionRecord(Fills, (*TransactionsResponse)(&fills[i])))
+		}
+
+	case Fiats:
+		transfers, err := e.API.GetFiatTransfers(off, limit)
+		if err != nil {
+			return nil, err
+		}
+		for i := range transfers {
+			records = append(records, NewFiatTransferRecord(&transfers[i]))
+		}
+
+	case Coins:
+		transfers, err := e.API.GetCoinTransfers(e.CurrencyPair, off, limit)
+		if err != nil {
+			return nil, err
+		}
+		for i := range transfers {
+			records = append(records, NewCoinTransferRecord(&transfers[i]))
+		}
+
+	default:
+		return nil, errors.Errorf("unknown category: %s", category)
+	}
+
+	return records, nil
+}
+
+// lastRecord finds the newest record that was already exported to path, it is nil when
+// the file does not exist or is empty.
+func (e *TransactionExporter) lastRecord(path string) (*TransactionRecord, error) {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var records []*TransactionRecord
+	switch e.Format {
+	case NDJSON:
+		records, err = readNDJSON(file)
+	default:
+		records, err = readCSV(file)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var last *TransactionRecord
+	for _, r := range records {
+		if last == nil || r.after(last) {
+			last = r
+		}
+	}
+
+	return last, nil
+}
+
+func writeCSV(w io.Writer, records []*TransactionRecord, header bool) error {
+	cw := csv.NewWriter(w)
+	if header {
+		if err := cw.Write(exportColumns); err != nil {
+			return err
+		}
+	}
+
+	for _, r := range records {
+		if err := cw.Write(r.Row()); err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+func readCSV(r io.Reader) ([]*TransactionRecord, error) {
+	rows, err := csv.NewReader(r).ReadAll()
+	if err != nil {
+		return nil, errors.Wrap(err, "csv read")
+	}
+
+	var records []*TransactionRecord
+	for i, row := range rows {
+		if i == 0 && len(row) > 0 && row[0] == exportColumns[0] {
+			continue // header
+		}
+		if len(row) < 3 {
+			return nil, errors.Errorf("csv row %d has %d columns", i, len(row))
+		}
+
+		timestamp, err := strconv.ParseInt(row[0], 10, 64)
+		if err != nil {
+			return nil, errors.Wrapf(err, "csv row %d timestamp", i)
+		}
+		id, err := strconv.ParseInt(row[2], 10, 64)
+		if err != nil {
+			return nil, errors.Wrapf(err, "csv row %d id", i)
+		}
+
+		// only the fields needed for resuming are read back.
+		records = append(records, &TransactionRecord{Timestamp: timestamp, ID: id})
+	}
+
+	return records, nil
+}
+
+func writeNDJSON(w io.Writer, records []*TransactionRecord) error {
+	enc := json.NewEncoder(w)
+	for _, r := range records {
+		if err := enc.Encode(r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func readNDJSON(r io.Reader) ([]*TransactionRecord, error) {
+	var records []*TransactionRecord
+
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+
+		var record TransactionRecord
+		err := json.Unmarshal(scanner.Bytes(), &record)
+		if err != nil {
+			return nil, errors.Wrapf(err, "ndjson line %d", line)
+		}
+		records = append(records, &record)
+	}
+
+	return records, scanner.Err()
+}
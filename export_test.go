@@ -0,0 +1,208 @@
+package korbit
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// historyHandler serves the transactions newest first and pages them like korbit does.
+// The history is all fills, the other categories are empty.
+func historyHandler(t *testing.T, history *[]map[string]interface{}) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("category") != Fills {
+			w.Write([]byte("[]"))
+			return
+		}
+
+		offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+		limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+
+		page := []map[string]interface{}{}
+		for i := len(*history) - 1 - offset; i >= 0 && len(page) < limit; i-- {
+			page = append(page, (*history)[i])
+		}
+
+		err := json.NewEncoder(w).Encode(page)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func fill(id int64) map[string]interface{} {
+	return map[string]interface{}{
+		"timestamp":   1500000000000 + id,
+		"completedAt": 1500000000000 + id,
+		"id":          id,
+		"type":        "buy",
+		"fee":         map[string]string{"currency": "eth", "value": "0.001"},
+		"balances": []map[string]string{
+			{"currency": "krw", "value": "1000"},
+			{"currency": "eth", "value": fmt.Sprintf("%d", id)},
+		},
+		"fillsDetail": map[string]interface{}{
+			"price":         map[string]string{"currency": "krw", "value": "300000"},
+			"amount":        map[string]string{"currency": "eth", "value": "1.5"},
+			"native_amount": map[string]string{"currency": "krw", "value": "450000"},
+			"orderID":       "77",
+		},
+	}
+}
+
+func TestExportResumes(t *testing.T) {
+	history := []map[string]interface{}{fill(1), fill(2), fill(3)}
+	k := newTestAPI(t, &TransactionHistory, historyHandler(t, &history))
+
+	for _, format := range []ExportFormat{CSV, NDJSON} {
+		path := filepath.Join(t.TempDir(), "fills."+format.Ext())
+		history = history[:3]
+
+		e := NewTransactionExporter(k, ETHKRW, format)
+		e.PageSize = 2
+
+		n, err := e.Export(Fills, path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != 3 {
+			t.Errorf("%s: exported %d records, want 3", format.Ext(), n)
+		}
+
+		history = append(history, fill(4), fill(5))
+		n, err = e.Export(Fills, path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != 2 {
+			t.Errorf("%s: resumed %d records, want 2", format.Ext(), n)
+		}
+
+		b, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		lines := strings.Split(strings.TrimSpace(string(b)), "\n")
+		if format == CSV {
+			if lines[0] != strings.Join(exportColumns, ",") {
+				t.Errorf("unexpected header: %s", lines[0])
+			}
+			lines = lines[1:]
+		}
+		if len(lines) != 5 {
+			t.Fatalf("%s: %d lines written, want 5", format.Ext(), len(lines))
+		}
+		if format == CSV && !strings.HasPrefix(lines[4], "1500000000005,1500000000005,5,fills,buy") {
+			t.Errorf("unexpected last row: %s", lines[4])
+		}
+	}
+}
+
+func TestExportPageSize(t *testing.T) {
+	e := NewTransactionExporter(NewKorbitAPI("", "", "", ""), ETHKRW, CSV)
+	e.PageSize = 0
+
+	_, err := e.Export(Fills, filepath.Join(t.TempDir(), "fills.csv"))
+	if err == nil {
+		t.Error("expected error for a zero page size")
+	}
+}
+
+func TestExportAllCategories(t *testing.T) {
+	history := []map[string]interface{}{fill(1), fill(2)}
+	k := newTestAPI(t, &TransactionHistory, historyHandler(t, &history))
+
+	added, err := NewTransactionExporter(k, ETHKRW, CSV).ExportAll(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if added[Fills] != 2 || added[Fiats] != 0 || added[Coins] != 0 {
+		t.Errorf("categories were not requested separately: %v", added)
+	}
+}
+
+func TestExportTransfers(t *testing.T) {
+	k := newTestAPI(t, &TransactionHistory, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Query().Get("category") {
+		case Fiats:
+			fmt.Fprint(w, `[{"timestamp": 1500000000001, "id": 1, "type": "withdrawal",
+				"fee": {"currency": "krw", "value": "1000"},
+				"fiatsDetail": {"amount": {"currency": "krw", "value": "50000"}, "bank": "shinhan",
+					"account": "110-123-456789", "status": "done"}}]`)
+		case Coins:
+			fmt.Fprint(w, `[{"timestamp": 1500000000002, "id": 2, "type": "deposit",
+				"fee": {"currency": "eth", "value": "0"},
+				"coinsDetail": {"amount": {"currency": "eth", "value": "1.5"}, "address": "0xabc",
+					"transaction_id": "0xdef", "status": "done"}}]`)
+		default:
+			fmt.Fprint(w, `[]`)
+		}
+	})
+
+	dir := t.TempDir()
+	_, err := NewTransactionExporter(k, ETHKRW, NDJSON).ExportAll(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for category, check := range map[string]func(*TransactionRecord) bool{
+		Fiats: func(r *TransactionRecord) bool {
+			return r.TransferAmount == 50000 && r.TransferBank == "shinhan" && r.FeeValue == 1000
+		},
+		Coins: func(r *TransactionRecord) bool {
+			return r.TransferAmount == 1.5 && r.TransferTransactionID == "0xdef" && r.TransferAddress == "0xabc"
+		},
+	} {
+		name := ETHKRW + "_" + category + ".ndjson"
+		if category == Fiats {
+			name = "fiats.ndjson"
+		}
+		b, err := ioutil.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var r TransactionRecord
+		if err := json.Unmarshal(b, &r); err != nil {
+			t.Fatal(err)
+		}
+		if r.Category != category || !check(&r) {
+			t.Errorf("%s details not exported: %+v", category, r)
+		}
+	}
+
+	// the krw transfers are not written again for another pair.
+	added, err := NewTransactionExporter(k, BTCKRW, NDJSON).ExportAll(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if added[Fiats] != 0 || added[Coins] != 1 {
+		t.Errorf("unexpected records for a second pair: %v", added)
+	}
+}
+
+func TestNewTransactionRecord(t *testing.T) {
+	b, err := json.Marshal([]map[string]interface{}{fill(9)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var resp []TransactionsResponse
+	err = json.Unmarshal(b, &resp)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := NewTransactionRecord(Fills, &resp[0])
+	if r.BalanceKRW != 1000 || r.BalanceCoin != 9 {
+		t.Errorf("balances not flattened: %+v", r)
+	}
+	if r.FillOrderID != 77 || r.FillNativeAmount != 450000 {
+		t.Errorf("fill detail not flattened: %+v", r)
+	}
+}
@@ -0,0 +1,130 @@
+package korbit
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// VolumeWindow is the period over which korbit sums up the trade volume that decides the
+// fee tier.
+const VolumeWindow = 30 * 24 * time.Hour
+
+// flexFloat decodes numbers that korbit sends either quoted or unquoted.
+type flexFloat float64
+
+func (f *flexFloat) UnmarshalJSON(b []byte) error {
+	s := string(bytes.Trim(b, `"`))
+	if s == "" || s == "null" {
+		*f = 0
+		return nil
+	}
+
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return errors.Wrapf(err, "parse number %s", b)
+	}
+
+	*f = flexFloat(v)
+	return nil
+}
+
+// PairVolume is the trade volume and the fee rates for a single currency pair. The fees
+// are rates, so 0.002 is 0.2%.
+type PairVolume struct {
+	Volume   float64
+	MakerFee float64
+	TakerFee float64
+}
+
+// VolumeAndFees is the response of the volume endpoint.
+type VolumeAndFees struct {
+	Pairs       map[string]PairVolume
+	TotalVolume float64
+	Timestamp   int64
+	From        time.Time // start of the volume window
+	To          time.Time // end of the volume window
+}
+
+// Fees gives the maker and taker fee rate of the pair.
+func (v *VolumeAndFees) Fees(pair string) (maker, taker float64, err error) {
+	p, ok := v.Pairs[pair]
+	if !ok {
+		return 0, 0, errors.Errorf("no fees for %s", pair)
+	}
+	return p.MakerFee, p.TakerFee, nil
+}
+
+// GetVolumeAndFees gets the 30 day trade volume and the current fee rates for the given
+// pairs, all pairs are returned when none are given.
+func (k *API) GetVolumeAndFees(pairs ...string) (*VolumeAndFees, error) {
+	pair := "all"
+	if len(pairs) > 0 {
+		pair = strings.Join(pairs, ",")
+	}
+
+	url := fmt.Sprintf("%s?currency_pair=%s", TradeVolumeAndFees, pair)
+	req, err := k.NewRequest(url, "GET", nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "make korbit volume request")
+	}
+
+	resp, err := k.Client.Do(req)
+	if err != nil {
+		return nil, errors.Wrap(err, "korbit volume fetch")
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("status: %d Header: %v", resp.StatusCode, resp.Header)
+	}
+
+	// the pairs are keys next to the totals so everything is decoded raw first.
+	var raw map[string]json.RawMessage
+	err = json.NewDecoder(resp.Body).Decode(&raw)
+	if err != nil {
+		return nil, errors.Wrap(err, "korbit volume json decode")
+	}
+
+	ret := VolumeAndFees{Pairs: map[string]PairVolume{}}
+	for key, value := range raw {
+		switch key {
+		case "total_volume":
+			var total flexFloat
+			err = json.Unmarshal(value, &total)
+			ret.TotalVolume = float64(total)
+		case "timestamp":
+			err = json.Unmarshal(value, &ret.Timestamp)
+		default:
+			var p struct {
+				Volume   flexFloat `json:"volume"`
+				MakerFee flexFloat `json:"maker_fee"`
+				TakerFee flexFloat `json:"taker_fee"`
+			}
+			if err = json.Unmarshal(value, &p); err == nil {
+				ret.Pairs[key] = PairVolume{
+					Volume:   float64(p.Volume),
+					MakerFee: float64(p.MakerFee),
+					TakerFee: float64(p.TakerFee),
+				}
+			}
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "korbit volume decode %s", key)
+		}
+	}
+
+	ret.To = time.Now()
+	if ret.Timestamp != 0 {
+		ret.To = millisToTime(ret.Timestamp)
+	}
+	ret.From = ret.To.Add(-VolumeWindow)
+
+	return &ret, nil
+}
@@ -0,0 +1,42 @@
+package korbit
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestGetVolumeAndFees(t *testing.T) {
+	k := newTestAPI(t, &TradeVolumeAndFees, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("currency_pair") != "btc_krw,eth_krw" {
+			t.Errorf("unexpected pairs: %s", r.URL.RawQuery)
+		}
+		fmt.Fprint(w, `{
+			"btc_krw": {"volume": "1500000", "maker_fee": "0.0008", "taker_fee": "0.002"},
+			"eth_krw": {"volume": 250000, "maker_fee": 0.0008, "taker_fee": 0.002},
+			"total_volume": "1750000",
+			"timestamp": 1520848032000
+		}`)
+	})
+
+	v, err := k.GetVolumeAndFees(BTCKRW, ETHKRW)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if v.TotalVolume != 1750000 || v.Pairs[ETHKRW].Volume != 250000 {
+		t.Errorf("unexpected volume: %+v", v)
+	}
+
+	maker, taker, err := v.Fees(BTCKRW)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if maker != 0.0008 || taker != 0.002 {
+		t.Errorf("unexpected fees: %f %f", maker, taker)
+	}
+
+	if v.To.Sub(v.From) != VolumeWindow || v.To.Unix() != 1520848032 {
+		t.Errorf("unexpected window: %v - %v", v.From, v.To)
+	}
+}
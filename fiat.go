@@ -0,0 +1,160 @@
+package korbit
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/pkg/errors"
+)
+
+// FiatIn and FiatOut are the types of KRW deposits and withdrawals.
+const (
+	FiatIn  = "fiat-in"
+	FiatOut = "fiat-out"
+)
+
+// FiatTransferStatus is the status of a KRW deposit or withdrawal.
+type FiatTransferStatus struct {
+	ID          int64    `json:"id,string"`
+	Type        string   `json:"type"`
+	Currency    string   `json:"currency"`
+	Amount      float64  `json:"amount,string"`
+	Fee         float64  `json:"fee,string"`
+	Status      string   `json:"status"`
+	CreatedAt   int64    `json:"createdAt"`
+	UpdatedAt   int64    `json:"updatedAt"`
+	CompletedAt int64    `json:"completedAt"`
+	Details     AcctInfo `json:"details"`
+}
+
+// RegisterBankAccount links the bank account that KRW is withdrawn to. Only the bank and
+// the account number are used, the owner is always the owner of the korbit account.
+func (k *API) RegisterBankAccount(acct AcctInfo) error {
+	if acct.Bank == "" || acct.Account == "" {
+		return errors.New("bank and account must be given")
+	}
+
+	data := url.Values{
+		"currency": {KRW},
+		"bank":     {acct.Bank},
+		"account":  {acct.Account},
+		"nonce":    {k.GetNonce()},
+	}
+
+	req, err := k.NewRequest(RegisterBank, "POST", data)
+	if err != nil {
+		return errors.Wrap(err, "make korbit register bank request")
+	}
+
+	resp, err := k.Client.Do(req)
+	if err != nil {
+		return errors.Wrap(err, "placing korbit register bank")
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return errors.Errorf("status: %d Header: %v", resp.StatusCode, resp.Header)
+	}
+
+	var registered struct {
+		Status string `json:"status"`
+	}
+	err = json.NewDecoder(resp.Body).Decode(&registered)
+	if err != nil {
+		return errors.Wrap(err, "json decode korbit register bank")
+	}
+
+	if registered.Status != Success {
+		return errors.Errorf("bank registration not successful: %s", registered.Status)
+	}
+
+	return nil
+}
+
+// GetBankAccount gets the linked bank account from the wallet.
+func (k *API) GetBankAccount() (*AcctInfo, error) {
+	// every wallet has the KRW accounts, so which pair is asked for does not matter.
+	wallet, err := k.GetWallet(BTCKRW)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, acct := range wallet.Out {
+		if acct.Currency == KRW && acct.Address.Account != "" {
+			info := acct.Address
+			if info.Owner == "" {
+				info.Owner = acct.RegisteredOwner
+			}
+			return &info, nil
+		}
+	}
+
+	return nil, errors.New("no bank account is registered")
+}
+
+// RequestFiatWithdrawal sends the amount of KRW to the linked bank account.
+func (k *API) RequestFiatWithdrawal(amount int64) (*WithdrawalResponse, error) {
+	if amount <= 0 {
+		return nil, errors.New("withdrawal amount must be positive")
+	}
+
+	data := url.Values{
+		"currency": {KRW},
+		"amount":   {strconv.FormatInt(amount, 10)},
+		"nonce":    {k.GetNonce()},
+	}
+
+	return k.postWithdrawal(FiatWithdrawal, data)
+}
+
+// CancelFiatWithdrawal cancels a KRW withdrawal that has not been sent yet.
+func (k *API) CancelFiatWithdrawal(id int64) (*WithdrawalResponse, error) {
+	data := url.Values{
+		"currency": {KRW},
+		"id":       {fmt.Sprintf("%d", id)},
+		"nonce":    {k.GetNonce()},
+	}
+
+	resp, err := k.postWithdrawal(CancelFiatOut, data)
+	if resp != nil && resp.TransferID == 0 {
+		resp.TransferID = id
+	}
+	return resp, err
+}
+
+// QueryFiatWithdrawal gets the status of a KRW withdrawal.
+func (k *API) QueryFiatWithdrawal(id int64) (*FiatTransferStatus, error) {
+	url := fmt.Sprintf("%s?currency=%s&id=%d", FiatStatus, KRW, id)
+	req, err := k.NewRequest(url, "GET", nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "make korbit fiat status request")
+	}
+
+	resp, err := k.Client.Do(req)
+	if err != nil {
+		return nil, errors.Wrap(err, "getting korbit fiat status")
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("status: %d Header: %v", resp.StatusCode, resp.Header)
+	}
+
+	var transfers []FiatTransferStatus
+	err = json.NewDecoder(resp.Body).Decode(&transfers)
+	if err != nil {
+		return nil, errors.Wrap(err, "json decode korbit fiat status")
+	}
+
+	// a deposit can have the same id as the withdrawal.
+	for i := range transfers {
+		if transfers[i].ID == id && transfers[i].Type == FiatOut {
+			return &transfers[i], nil
+		}
+	}
+
+	return nil, errors.Errorf("no krw withdrawal with id %d", id)
+}
@@ -0,0 +1,88 @@
+package korbit
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestRegisterAndGetBankAccount(t *testing.T) {
+	newTestAPI(t, &RegisterBank, func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		if r.Form.Get("currency") != KRW || r.Form.Get("bank") != "KEB" || r.Form.Get("account") != "123" {
+			t.Errorf("unexpected register form: %v", r.Form)
+		}
+		fmt.Fprint(w, `{"status": "success"}`)
+	})
+	k := newTestAPI(t, &WalletURL, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"out": [{"currency": "btc", "address": {"address": "1Out"}},
+			{"currency": "krw", "registeredOwner": "Hong",
+			"address": {"bank": "KEB", "account": "123"}}]}`)
+	})
+
+	err := k.RegisterBankAccount(AcctInfo{Bank: "KEB", Account: "123"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if k.RegisterBankAccount(AcctInfo{Bank: "KEB"}) == nil {
+		t.Error("expected error for a missing account number")
+	}
+
+	acct, err := k.GetBankAccount()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if acct.Bank != "KEB" || acct.Account != "123" || acct.Owner != "Hong" {
+		t.Errorf("unexpected bank account: %+v", acct)
+	}
+}
+
+func TestFiatWithdrawal(t *testing.T) {
+	newTestAPI(t, &FiatWithdrawal, func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		if r.Form.Get("currency") != KRW || r.Form.Get("amount") != "50000" {
+			t.Errorf("unexpected withdrawal form: %v", r.Form)
+		}
+		fmt.Fprint(w, `{"transferId": "88", "status": "success"}`)
+	})
+	newTestAPI(t, &FiatStatus, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("id") != "88" {
+			t.Errorf("unexpected status query: %s", r.URL.RawQuery)
+		}
+		// a deposit with the same id comes first.
+		fmt.Fprint(w, `[{"id": "88", "type": "fiat-in", "currency": "krw", "amount": "70000",
+			"fee": "0", "status": "done", "createdAt": 1500000000000},
+			{"id": "88", "type": "fiat-out", "currency": "krw", "amount": "50000",
+			"fee": "1000", "status": "pending", "createdAt": 1500000000000,
+			"details": {"bank": "KEB", "account": "123"}}]`)
+	})
+	k := newTestAPI(t, &CancelFiatOut, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"status": "success"}`)
+	})
+
+	resp, err := k.RequestFiatWithdrawal(50000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.TransferID != 88 {
+		t.Errorf("unexpected transfer id: %d", resp.TransferID)
+	}
+
+	transfer, err := k.QueryFiatWithdrawal(88)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if transfer.Status != "pending" || transfer.Amount != 50000 || transfer.Details.Bank != "KEB" {
+		t.Errorf("unexpected transfer: %+v", transfer)
+	}
+
+	resp, err = k.CancelFiatWithdrawal(88)
+	if err != nil || resp.TransferID != 88 {
+		t.Errorf("unexpected cancel: %+v %v", resp, err)
+	}
+
+	_, err = k.RequestFiatWithdrawal(0)
+	if err == nil {
+		t.Error("expected error for a zero withdrawal")
+	}
+}
@@ -0,0 +1,103 @@
+package korbit
+
+import (
+	"encoding/json"
+
+	"github.com/pkg/errors"
+)
+
+// Fill is an order fill from the fills category of the transaction history.
+type Fill TransactionsResponse
+
+// CoinTransfer is a coin deposit or withdrawal from the coins category of the transaction
+// history.
+type CoinTransfer struct {
+	Timestamp   int64      `json:"timestamp"`
+	CompletedAt int64      `json:"completedAt"`
+	ID          int64      `json:"id"`
+	Type        string     `json:"type"`
+	Fee         Currency   `json:"fee"`
+	Balances    []Currency `json:"balances"`
+	CoinsDetail CoinDetail `json:"coinsDetail"`
+}
+
+// CoinDetail is the details of a coin transfer. DestinationTag is only set for XRP.
+type CoinDetail struct {
+	Amount         Currency `json:"amount"`
+	Address        string   `json:"address"`
+	DestinationTag string   `json:"destination_tag"`
+	TransactionID  string   `json:"transaction_id"`
+	Status         string   `json:"status"`
+}
+
+// FiatTransfer is a KRW deposit or withdrawal from the fiats category of the transaction
+// history.
+type FiatTransfer struct {
+	Timestamp   int64      `json:"timestamp"`
+	CompletedAt int64      `json:"completedAt"`
+	ID          int64      `json:"id"`
+	Type        string     `json:"type"`
+	Fee         Currency   `json:"fee"`
+	Balances    []Currency `json:"balances"`
+	FiatsDetail FiatDetail `json:"fiatsDetail"`
+}
+
+// FiatDetail is the details of a KRW transfer.
+type FiatDetail struct {
+	Amount  Currency `json:"amount"`
+	Bank    string   `json:"bank"`
+	Account string   `json:"account"`
+	Owner   string   `json:"owner"`
+	Status  string   `json:"status"`
+}
+
+// GetFills gets the order fills of the pair, newest first. The order id can be left
+// empty to get the fills of every order.
+func (k *API) GetFills(pair, offset, limit, orderID string) ([]Fill, error) {
+	b, err := k.transactionHistory(pair, Fills, offset, limit, orderID)
+	if err != nil {
+		return nil, err
+	}
+
+	var fills []Fill
+	err = json.Unmarshal(b, &fills)
+	if err != nil {
+		return nil, errors.Wrapf(err, "json decode korbit fills for %s", pair)
+	}
+
+	return fills, nil
+}
+
+// GetCoinTransfers gets the deposits and withdrawals of the coin of the pair, newest
+// first.
+func (k *API) GetCoinTransfers(pair, offset, limit string) ([]CoinTransfer, error) {
+	b, err := k.transactionHistory(pair, Coins, offset, limit, "")
+	if err != nil {
+		return nil, err
+	}
+
+	var transfers []CoinTransfer
+	err = json.Unmarshal(b, &transfers)
+	if err != nil {
+		return nil, errors.Wrapf(err, "json decode korbit coin transfers for %s", pair)
+	}
+
+	return transfers, nil
+}
+
+// GetFiatTransfers gets the KRW deposits and withdrawals, newest first.
+func (k *API) GetFiatTransfers(offset, limit string) ([]FiatTransfer, error) {
+	// korbit wants a pair even though the KRW transfers are the same for all of them.
+	b, err := k.transactionHistory(BTCKRW, Fiats, offset, limit, "")
+	if err != nil {
+		return nil, err
+	}
+
+	var transfers []FiatTransfer
+	err = json.Unmarshal(b, &transfers)
+	if err != nil {
+		return nil, errors.Wrap(err, "json decode korbit fiat transfers")
+	}
+
+	return transfers, nil
+}
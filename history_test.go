@@ -0,0 +1,92 @@
+package korbit
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestGetFills(t *testing.T) {
+	k := newTestAPI(t, &TransactionHistory, func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if q.Get("category") != Fills || q.Get("order_id") != "1001" {
+			t.Errorf("unexpected history query: %s", r.URL.RawQuery)
+		}
+		fmt.Fprint(w, `[{"timestamp":1500000000000,"completedAt":1500000000000,"id":"77",
+			"type":"buy","fee":{"currency":"btc","value":"0.0001"},
+			"balances":[{"currency":"krw","value":"100000"},{"currency":"btc","value":"0.5"}],
+			"fillsDetail":{"price":{"currency":"krw","value":"3000000"},
+			"amount":{"currency":"btc","value":"0.1"},
+			"native_amount":{"currency":"krw","value":"300000"},"orderID":"1001"}}]`)
+	})
+
+	fills, err := k.GetFills(BTCKRW, "", "", "1001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fills) != 1 {
+		t.Fatalf("unexpected fills: %+v", fills)
+	}
+
+	f := fills[0]
+	if f.ID != 77 || f.Type != Buy || f.FillsDetail.Price.Value != 3000000 ||
+		f.FillsDetail.Amount.Value != 0.1 || f.FillsDetail.OrderID != 1001 || len(f.Balances) != 2 {
+		t.Errorf("unexpected fill: %+v", f)
+	}
+}
+
+func TestGetCoinTransfers(t *testing.T) {
+	k := newTestAPI(t, &TransactionHistory, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("category") != Coins {
+			t.Errorf("unexpected history query: %s", r.URL.RawQuery)
+		}
+		fmt.Fprint(w, `[{"timestamp":1500000000000,"completedAt":0,"id":31,
+			"type":"coin-out","fee":{"currency":"xrp","value":"1"},
+			"balances":[{"currency":"xrp","value":"74"}],
+			"coinsDetail":{"amount":{"currency":"xrp","value":"25"},"address":"rAddress",
+			"destination_tag":"1234","transaction_id":"ABCDEF","status":"pending"}}]`)
+	})
+
+	transfers, err := k.GetCoinTransfers(XRPKRW, "0", "10")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(transfers) != 1 {
+		t.Fatalf("unexpected transfers: %+v", transfers)
+	}
+
+	tr := transfers[0]
+	d := tr.CoinsDetail
+	if tr.ID != 31 || tr.Type != "coin-out" || tr.Fee.Value != 1 || d.Amount.Value != 25 ||
+		d.Address != "rAddress" || d.DestinationTag != "1234" || d.TransactionID != "ABCDEF" ||
+		d.Status != "pending" {
+		t.Errorf("unexpected transfer: %+v", tr)
+	}
+}
+
+func TestGetFiatTransfers(t *testing.T) {
+	k := newTestAPI(t, &TransactionHistory, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("category") != Fiats {
+			t.Errorf("unexpected history query: %s", r.URL.RawQuery)
+		}
+		fmt.Fprint(w, `[{"timestamp": 1500000000000, "completedAt": 1500000100000, "id": "12",
+			"type": "fiat-in", "fee": {"currency": "krw", "value": "0"},
+			"balances": [{"currency": "krw", "value": "150000"}],
+			"fiatsDetail": {"amount": {"currency": "krw", "value": "100000"}, "bank": "KEB",
+			"account": "123", "owner": "Hong", "status": "filled"}}]`)
+	})
+
+	transfers, err := k.GetFiatTransfers("0", "10")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(transfers) != 1 {
+		t.Fatalf("unexpected transfers: %+v", transfers)
+	}
+
+	tr := transfers[0]
+	if tr.ID != 12 || tr.Type != "fiat-in" || tr.FiatsDetail.Amount.Value != 100000 ||
+		tr.FiatsDetail.Bank != "KEB" || tr.FiatsDetail.Status != "filled" {
+		t.Errorf("unexpected transfer: %+v", tr)
+	}
+}
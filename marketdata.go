@@ -0,0 +1,336 @@
+package korbit
+
+import (
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// TickerEvent and the other kinds here are the kinds of MarketEvent.
+const (
+	TickerEvent    = "ticker"
+	OrderbookEvent = "orderbook"
+	TradeEvent     = "trade"
+)
+
+// WebSocketMode and PollingMode select the MarketDataSource implementation.
+const (
+	WebSocketMode = "websocket"
+	PollingMode   = "polling"
+)
+
+// MarketEvent is a single piece of market data, only the field matching Type is set.
+type MarketEvent struct {
+	Type         string
+	CurrencyPair string
+	Ticker       *Prices
+	Orderbook    *Orderbook
+	Trade        *Trade
+}
+
+// Time gives the time of the data in the event, zero when the event has none.
+func (e MarketEvent) Time() time.Time {
+	switch {
+	case e.Type == TickerEvent && e.Ticker != nil:
+		if e.Ticker.Timestamp.IsZero() {
+			return millisToTime(e.Ticker.TimestampMillis)
+		}
+		return e.Ticker.Timestamp
+	case e.Type == OrderbookEvent && e.Orderbook != nil:
+		return millisToTime(e.Orderbook.Timestamp)
+	case e.Type == TradeEvent && e.Trade != nil:
+		return e.Trade.Time()
+	}
+	return time.Time{}
+}
+
+// MarketDataSource delivers tickers, orderbooks and trades for the subscribed pairs. The
+// websocket and polling sources deliver the same events so either can be used. Only the
+// polling source knows the tids of trades, use Trade.Key to tell trades apart.
+type MarketDataSource interface {
+	Subscribe(pairs ...string) error
+	Start()
+	Events() <-chan MarketEvent
+	Errors() <-chan error
+	Close() error
+}
+
+// MarketDataConfig configures a MarketDataSource. The kinds of data that are wanted are
+// switched on with Tickers, Orderbooks and Trades.
+type MarketDataConfig struct {
+	Mode       string
+	Interval   time.Duration // how often the polling source polls, at most a day
+	Tickers    bool
+	Orderbooks bool
+	Trades     bool
+}
+
+// NewMarketDataSource returns the source that the config asks for.
+func NewMarketDataSource(k *API, cfg MarketDataConfig) (MarketDataSource, error) {
+	switch cfg.Mode {
+	case WebSocketMode:
+		return NewWebSocketSource(k, cfg), nil
+	case PollingMode:
+		if cfg.Interval <= 0 || cfg.Interval > 24*time.Hour {
+			return nil, errors.New("polling needs an interval between zero and a day")
+		}
+		return NewPollingSource(k, cfg), nil
+	}
+	return nil, errors.Errorf("unrecognized market data mode: %s", cfg.Mode)
+}
+
+// WebSocketSource is a MarketDataSource on top of a Stream.
+type WebSocketSource struct {
+	Stream *Stream
+
+	cfg    MarketDataConfig
+	events chan MarketEvent
+}
+
+// NewWebSocketSource returns a websocket source, nothing is dialed until Start.
+func NewWebSocketSource(k *API, cfg MarketDataConfig) *WebSocketSource {
+	return &WebSocketSource{
+		Stream: NewStream(k),
+		cfg:    cfg,
+		events: make(chan MarketEvent, 64),
+	}
+}
+
+// Subscribe subscribes to the configured channels for the pairs.
+func (w *WebSocketSource) Subscribe(pairs ...string) error {
+	subs := map[string]bool{
+		TickerChannel:    w.cfg.Tickers,
+		OrderbookChannel: w.cfg.Orderbooks,
+		TradeChannel:     w.cfg.Trades,
+	}
+
+	for channel, wanted := range subs {
+		if !wanted {
+			continue
+		}
+		err := w.Stream.Subscribe(channel, pairs...)
+		if err != nil {
+			return errors.Wrapf(err, "subscribe to %s", channel)
+		}
+	}
+
+	return nil
+}
+
+// Start starts the stream and turns what it delivers into events until Close.
+func (w *WebSocketSource) Start() {
+	w.Stream.Start()
+
+	go func() {
+		defer close(w.events)
+
+		tickers, books, trades := w.Stream.Tickers, w.Stream.Orderbooks, w.Stream.Trades
+		for tickers != nil || books != nil || trades != nil {
+			var e MarketEvent
+			select {
+			case p, ok := <-tickers:
+				if !ok {
+					tickers = nil
+					continue
+				}
+				e = MarketEvent{Type: TickerEvent, CurrencyPair: p.CurrencyPair, Ticker: p}
+			case o, ok := <-books:
+				if !ok {
+					books = nil
+					continue
+				}
+				e = MarketEvent{Type: OrderbookEvent, CurrencyPair: o.CurrencyPair, Orderbook: o}
+			case t, ok := <-trades:
+				if !ok {
+					trades = nil
+					continue
+				}
+				e = MarketEvent{Type: TradeEvent, CurrencyPair: t.CurrencyPair, Trade: &t}
+			}
+
+			// the events may no longer be read once the source is closed.
+			select {
+			case w.events <- e:
+			case <-w.Stream.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Events gives the channel the events are delivered on, it is closed after Close.
+func (w *WebSocketSource) Events() <-chan MarketEvent {
+	return w.events
+}
+
+// Errors gives the errors of the stream.
+func (w *WebSocketSource) Errors() <-chan error {
+	return w.Stream.Errors
+}
+
+// Close closes the stream.
+func (w *WebSocketSource) Close() error {
+	return w.Stream.Close()
+}
+
+// PollingSource is a MarketDataSource that polls the REST endpoints. Tickers and
+// orderbooks are only delivered when their timestamp moved and trades only once, so it
+// delivers what the websocket would, just later.
+type PollingSource struct {
+	api    *API
+	cfg    MarketDataConfig
+	events chan MarketEvent
+	errors chan error
+	stop   chan struct{}
+	once   sync.Once
+
+	mu      sync.Mutex
+	pairs   []string
+	started bool
+
+	// only used by the polling goroutine.
+	lastPrice map[string]int64 // pair -> timestamp of the last ticker
+	lastBook  map[string]int64 // pair -> timestamp of the last orderbook
+	lastTID   map[string]int64 // pair -> tid of the last trade
+}
+
+// NewPollingSource returns a polling source, nothing is polled until Start.
+func NewPollingSource(k *API, cfg MarketDataConfig) *PollingSource {
+	return &PollingSource{
+		api:       k,
+		cfg:       cfg,
+		events:    make(chan MarketEvent, 64),
+		errors:    make(chan error, 1),
+		stop:      make(chan struct{}),
+		lastPrice: map[string]int64{},
+		lastBook:  map[string]int64{},
+		lastTID:   map[string]int64{},
+	}
+}
+
+// Subscribe adds the pairs to the ones that are polled.
+func (p *PollingSource) Subscribe(pairs ...string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.pairs = append(p.pairs, pairs...)
+	return nil
+}
+
+// Start polls in the background until Close.
+func (p *PollingSource) Start() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.started {
+		return
+	}
+	p.started = true
+
+	go func() {
+		defer close(p.events)
+
+		ticker := time.NewTicker(p.cfg.Interval)
+		defer ticker.Stop()
+
+		for {
+			p.mu.Lock()
+			pairs := append([]string(nil), p.pairs...)
+			p.mu.Unlock()
+
+			for _, pair := range pairs {
+				p.poll(pair)
+			}
+
+			select {
+			case <-p.stop:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Events gives the channel the events are delivered on, it is closed after Close.
+func (p *PollingSource) Events() <-chan MarketEvent {
+	return p.events
+}
+
+// Errors gives the channel that polling errors are sent on. Errors are dropped while
+// the previous one has not been received.
+func (p *PollingSource) Errors() <-chan error {
+	return p.errors
+}
+
+// Close stops the polling.
+func (p *PollingSource) Close() error {
+	p.once.Do(func() { close(p.stop) })
+	return nil
+}
+
+// poll fetches the configured data of the pair and delivers what is new.
+func (p *PollingSource) poll(pair string) {
+	if p.cfg.Tickers {
+		prices, err := p.api.GetPrices(pair)
+		if err != nil {
+			p.report(err)
+		} else if prices.TimestampMillis > p.lastPrice[pair] {
+			p.lastPrice[pair] = prices.TimestampMillis
+			p.send(MarketEvent{Type: TickerEvent, CurrencyPair: pair, Ticker: prices})
+		}
+	}
+
+	if p.cfg.Orderbooks {
+		book, err := p.api.GetOrderbook(pair)
+		if err != nil {
+			p.report(err)
+		} else if book.Timestamp > p.lastBook[pair] {
+			p.lastBook[pair] = book.Timestamp
+			p.send(MarketEvent{Type: OrderbookEvent, CurrencyPair: pair, Orderbook: book})
+		}
+	}
+
+	if p.cfg.Trades {
+		trades, err := p.api.GetTrades(pair, tradeWindow(p.cfg.Interval))
+		if err != nil {
+			p.report(err)
+			return
+		}
+
+		// trades come newest first.
+		for i := len(trades) - 1; i >= 0; i-- {
+			t := trades[i]
+			if t.TID <= p.lastTID[pair] {
+				continue
+			}
+			p.lastTID[pair] = t.TID
+			p.send(MarketEvent{Type: TradeEvent, CurrencyPair: pair, Trade: &t})
+		}
+	}
+}
+
+// tradeWindow gives the shortest trade window that covers the time between two polls.
+func tradeWindow(interval time.Duration) string {
+	switch {
+	case interval <= time.Minute:
+		return Minute
+	case interval <= time.Hour:
+		return Hour
+	}
+	return Day
+}
+
+func (p *PollingSource) send(e MarketEvent) {
+	select {
+	case p.events <- e:
+	case <-p.stop:
+	}
+}
+
+func (p *PollingSource) report(err error) {
+	select {
+	case p.errors <- err:
+	default:
+	}
+}
@@ -0,0 +1,158 @@
+package korbit
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// collect reads events until it has one of each kind.
+func collect(t *testing.T, src MarketDataSource) map[string]MarketEvent {
+	got := map[string]MarketEvent{}
+	timeout := time.After(5 * time.Second)
+
+	for len(got) < 3 {
+		select {
+		case e := <-src.Events():
+			if _, ok := got[e.Type]; ok {
+				t.Errorf("%s delivered twice", e.Type)
+			}
+			got[e.Type] = e
+		case err := <-src.Errors():
+			t.Fatal(err)
+		case <-timeout:
+			t.Fatalf("only got %v", got)
+		}
+	}
+
+	return got
+}
+
+func checkEvents(t *testing.T, mode string, got map[string]MarketEvent) {
+	if e := got[TickerEvent]; e.CurrencyPair != BTCKRW || e.Ticker.Last != 9198500 {
+		t.Errorf("%s: unexpected ticker event: %+v", mode, e)
+	}
+	if e := got[OrderbookEvent]; e.CurrencyPair != BTCKRW || e.Orderbook.Bids[0].Price != 9192500 {
+		t.Errorf("%s: unexpected orderbook event: %+v", mode, e)
+	}
+	if e := got[TradeEvent]; e.CurrencyPair != BTCKRW || e.Trade.Price != 9198000 {
+		t.Errorf("%s: unexpected trade event: %+v", mode, e)
+	}
+}
+
+func TestMarketDataSources(t *testing.T) {
+	cfg := MarketDataConfig{Interval: 10 * time.Millisecond, Tickers: true, Orderbooks: true, Trades: true}
+
+	newTestAPI(t, &DetailedTicker, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"timestamp": 1558590089274, "last": "9198500", "bid": "9192500", "ask": "9198000",
+			"low": "9171500", "high": "9599000", "volume": "1539.18571988"}`)
+	})
+	newTestAPI(t, &GetOrderbook, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"timestamp": 1558590089274, "bids": [["9192500", "0.5", "1"]],
+			"asks": [["9198000", "1.25", "1"]]}`)
+	})
+	k := newTestAPI(t, &PublicTrades, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `[{"timestamp": 1558590089274, "tid": "1", "price": "9198000",
+			"amount": "0.01", "type": "buy"}]`)
+	})
+
+	cfg.Mode = PollingMode
+	polling, err := NewMarketDataSource(k, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	polling.Subscribe(BTCKRW)
+	polling.Start()
+	polled := collect(t, polling)
+	checkEvents(t, PollingMode, polled)
+
+	// the same data again on later polls is not delivered twice.
+	select {
+	case e := <-polling.Events():
+		t.Errorf("repeated event: %+v", e)
+	case <-time.After(50 * time.Millisecond):
+	}
+	polling.Close()
+
+	url := newStreamServer(t, func(conn *websocket.Conn, channels []string) {
+		push(conn, TickerChannel, `"last": "9198500", "bid": "9192500", "ask": "9198000"`)
+		push(conn, OrderbookChannel, `"bids": [{"price": "9192500", "amount": "0.5"}],
+			"asks": [{"price": "9198000", "amount": "1.25"}]`)
+		push(conn, TradeChannel, `"price": "9198000", "amount": "0.01", "taker": "buy"`)
+		conn.ReadMessage()
+	})
+
+	cfg.Mode = WebSocketMode
+	ws, err := NewMarketDataSource(k, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ws.(*WebSocketSource).Stream.URL = url
+	ws.Subscribe(BTCKRW)
+	ws.Start()
+	streamed := collect(t, ws)
+	checkEvents(t, WebSocketMode, streamed)
+	ws.Close()
+
+	if polled[TradeEvent].Trade.Key() != streamed[TradeEvent].Trade.Key() {
+		t.Errorf("the same trade has different keys: %s and %s",
+			polled[TradeEvent].Trade.Key(), streamed[TradeEvent].Trade.Key())
+	}
+
+	_, err = NewMarketDataSource(k, MarketDataConfig{Mode: "carrier pigeon"})
+	if err == nil {
+		t.Error("expected error for unknown mode")
+	}
+}
+
+func TestWebSocketSourceCloseUnread(t *testing.T) {
+	url := newStreamServer(t, func(conn *websocket.Conn, channels []string) {
+		for i := 0; i < 200; i++ {
+			push(conn, TradeChannel, `"price": "9198000", "amount": "0.01", "taker": "buy"`)
+		}
+		conn.ReadMessage()
+	})
+
+	ws := NewWebSocketSource(nil, MarketDataConfig{Mode: WebSocketMode, Trades: true})
+	ws.Stream.URL = url
+	ws.Subscribe(BTCKRW)
+	ws.Start()
+
+	// wait for the events to back up with nobody reading them.
+	for deadline := time.Now().Add(5 * time.Second); len(ws.events) < cap(ws.events); {
+		if time.Now().After(deadline) {
+			t.Fatal("events did not back up")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	ws.Close()
+
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case _, ok := <-ws.Events():
+			if !ok {
+				return
+			}
+		case <-timeout:
+			t.Fatal("events were not closed after Close")
+		}
+	}
+}
+
+func TestTradeWindow(t *testing.T) {
+	for interval, want := range map[time.Duration]string{
+		time.Second:      Minute,
+		time.Minute:      Minute,
+		5 * time.Minute:  Hour,
+		2 * time.Hour:    Day,
+		30 * time.Minute: Hour,
+	} {
+		if got := tradeWindow(interval); got != want {
+			t.Errorf("window for %s is %s, want %s", interval, got, want)
+		}
+	}
+}
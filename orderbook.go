@@ -0,0 +1,239 @@
+package korbit
+
+import (
+	"github.com/pkg/errors"
+)
+
+// Aggregate gives a copy of the book with the levels merged into buckets of step KRW.
+// Bids are rounded down and asks up so that the aggregated book is never better than the
+// real one.
+func (o *Orderbook) Aggregate(step int64) (*Orderbook, error) {
+	if step <= 0 {
+		return nil, errors.New("step must be positive")
+	}
+
+	return &Orderbook{
+		CurrencyPair: o.CurrencyPair,
+		Timestamp:    o.Timestamp,
+		Bids:         aggregateLevels(o.Bids, step, false),
+		Asks:         aggregateLevels(o.Asks, step, true),
+	}, nil
+}
+
+// aggregateLevels merges sorted levels whose prices round to the same bucket.
+func aggregateLevels(levels []OrderbookOrder, step int64, up bool) []OrderbookOrder {
+	var ret []OrderbookOrder
+
+	for _, l := range levels {
+		price := l.Price - l.Price%step
+		if up && l.Price%step != 0 {
+			price += step
+		}
+
+		last := len(ret) - 1
+		if last >= 0 && ret[last].Price == price {
+			ret[last].Qty += l.Qty
+			ret[last].Orders += l.Orders
+			continue
+		}
+
+		ret = append(ret, OrderbookOrder{Price: price, Qty: l.Qty, Orders: l.Orders})
+	}
+
+	return ret
+}
+
+// FillEstimate is what walking the orderbook says a market order would get. Slippage is
+// how much worse the average price is than the best price, in KRW and basis points.
+type FillEstimate struct {
+	Side        string
+	Qty         float64 // coin amount that would be filled
+	Notional    float64 // KRW that would be paid or received
+	AvgPrice    float64
+	BestPrice   int64
+	WorstPrice  int64
+	Slippage    float64
+	SlippageBps float64
+	Levels      int  // price levels that would be touched
+	Partial     bool // the book is not deep enough to fill everything
+}
+
+// BestBid gives the highest bid, false if there are no bids.
+func (o *Orderbook) BestBid() (OrderbookOrder, bool) {
+	if len(o.Bids) == 0 {
+		return OrderbookOrder{}, false
+	}
+	return o.Bids[0], true
+}
+
+// BestAsk gives the lowest ask, false if there are no asks.
+func (o *Orderbook) BestAsk() (OrderbookOrder, bool) {
+	if len(o.Asks) == 0 {
+		return OrderbookOrder{}, false
+	}
+	return o.Asks[0], true
+}
+
+// top gives the best bid and ask, erroring when a side is empty.
+func (o *Orderbook) top() (bid, ask OrderbookOrder, err error) {
+	bid, ok := o.BestBid()
+	if !ok {
+		return bid, ask, errors.New("orderbook has no bids")
+	}
+	ask, ok = o.BestAsk()
+	if !ok {
+		return bid, ask, errors.New("orderbook has no asks")
+	}
+	return bid, ask, nil
+}
+
+// Spread gives the difference between the best ask and the best bid in KRW.
+func (o *Orderbook) Spread() (int64, error) {
+	bid, ask, err := o.top()
+	if err != nil {
+		return 0, err
+	}
+	return ask.Price - bid.Price, nil
+}
+
+// SpreadBps gives the spread in basis points of the mid price.
+func (o *Orderbook) SpreadBps() (float64, error) {
+	spread, err := o.Spread()
+	if err != nil {
+		return 0, err
+	}
+	mid, err := o.Mid()
+	if err != nil {
+		return 0, err
+	}
+	return float64(spread) / mid * 10000, nil
+}
+
+// Mid gives the price halfway between the best bid and the best ask.
+func (o *Orderbook) Mid() (float64, error) {
+	bid, ask, err := o.top()
+	if err != nil {
+		return 0, err
+	}
+	return float64(bid.Price+ask.Price) / 2, nil
+}
+
+// Microprice gives the mid price weighted by the quantity at the top of the book, it
+// leans towards the side with less quantity since that is the one more likely to move.
+func (o *Orderbook) Microprice() (float64, error) {
+	bid, ask, err := o.top()
+	if err != nil {
+		return 0, err
+	}
+
+	total := bid.Qty + ask.Qty
+	if total == 0 {
+		return float64(bid.Price+ask.Price) / 2, nil
+	}
+	return (float64(bid.Price)*ask.Qty + float64(ask.Price)*bid.Qty) / total, nil
+}
+
+// levels gives the side of the book that a taker order of the side trades against. The
+// orderbook methods all take the side of the taker, buy or sell.
+func (o *Orderbook) levels(side string) ([]OrderbookOrder, error) {
+	switch side {
+	case Buy:
+		return o.Asks, nil
+	case Sell:
+		return o.Bids, nil
+	}
+	return nil, errors.Errorf("side must be buy or sell, got: %s", side)
+}
+
+// Depth gives the quantity and the KRW notional that a taker order of the side could
+// trade within bps basis points of the mid price, so sell gives the depth of the bids.
+func (o *Orderbook) Depth(side string, bps float64) (qty, notional float64, err error) {
+	levels, err := o.levels(side)
+	if err != nil {
+		return 0, 0, err
+	}
+	mid, err := o.Mid()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	limit := mid * (1 + bps/10000)
+	if side == Sell {
+		limit = mid * (1 - bps/10000)
+	}
+
+	for _, l := range levels {
+		if side == Sell && float64(l.Price) < limit || side == Buy && float64(l.Price) > limit {
+			break
+		}
+		qty += l.Qty
+		notional += l.Qty * float64(l.Price)
+	}
+
+	return qty, notional, nil
+}
+
+// EstimateFill walks the book to estimate a market order of the side for qty coins.
+func (o *Orderbook) EstimateFill(side string, qty float64) (*FillEstimate, error) {
+	if qty <= 0 {
+		return nil, errors.New("qty must be positive")
+	}
+	return o.estimate(side, func(e *FillEstimate, l OrderbookOrder) float64 {
+		return qty - e.Qty
+	})
+}
+
+// EstimateFillNotional walks the book to estimate a market order of the side that pays
+// or receives krw.
+func (o *Orderbook) EstimateFillNotional(side string, krw float64) (*FillEstimate, error) {
+	if krw <= 0 {
+		return nil, errors.New("krw must be positive")
+	}
+	return o.estimate(side, func(e *FillEstimate, l OrderbookOrder) float64 {
+		return (krw - e.Notional) / float64(l.Price)
+	})
+}
+
+// estimate takes from each level the smaller of its quantity and what remaining says is
+// still needed, until nothing is left.
+func (o *Orderbook) estimate(side string, remaining func(*FillEstimate, OrderbookOrder) float64) (
+	*FillEstimate, error) {
+
+	levels, err := o.levels(side)
+	if err != nil {
+		return nil, err
+	}
+	if len(levels) == 0 {
+		return nil, errors.Errorf("orderbook has nothing to %s against", side)
+	}
+
+	e := FillEstimate{Side: side, BestPrice: levels[0].Price}
+	for _, l := range levels {
+		need := remaining(&e, l)
+		if need <= roundingSlack {
+			break
+		}
+
+		take := l.Qty
+		if need < take {
+			take = need
+		}
+
+		e.Qty += take
+		e.Notional += take * float64(l.Price)
+		e.WorstPrice = l.Price
+		e.Levels++
+	}
+	e.Partial = remaining(&e, levels[len(levels)-1]) > roundingSlack
+
+	if e.Qty > 0 {
+		e.AvgPrice = e.Notional / e.Qty
+		e.Slippage = e.AvgPrice - float64(e.BestPrice)
+		if side == Sell {
+			e.Slippage = -e.Slippage
+		}
+		e.SlippageBps = e.Slippage / float64(e.BestPrice) * 10000
+	}
+
+	return &e, nil
+}
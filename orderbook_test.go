@@ -0,0 +1,103 @@
+package korbit
+
+import (
+	"math"
+	"testing"
+)
+
+func testBook() *Orderbook {
+	return &Orderbook{
+		Bids: []OrderbookOrder{{Price: 9990, Qty: 1}, {Price: 9980, Qty: 2}, {Price: 9900, Qty: 5}},
+		Asks: []OrderbookOrder{{Price: 10010, Qty: 3}, {Price: 10020, Qty: 1}, {Price: 10100, Qty: 4}},
+	}
+}
+
+func near(a, b float64) bool {
+	return math.Abs(a-b) < 1e-6
+}
+
+func TestOrderbookTop(t *testing.T) {
+	o := testBook()
+
+	spread, err := o.Spread()
+	if err != nil || spread != 20 {
+		t.Errorf("spread %d %v, want 20", spread, err)
+	}
+
+	mid, _ := o.Mid()
+	bps, _ := o.SpreadBps()
+	if mid != 10000 || !near(bps, 20) {
+		t.Errorf("mid %f bps %f, want 10000 and 20", mid, bps)
+	}
+
+	// three times more on the ask so the microprice leans to the bid.
+	micro, _ := o.Microprice()
+	if !near(micro, (9990*3+10010*1)/4.0) {
+		t.Errorf("microprice %f", micro)
+	}
+
+	qty, notional, err := o.Depth(Sell, 25)
+	if err != nil || qty != 3 || notional != 9990+9980*2 {
+		t.Errorf("sell depth %f %f %v", qty, notional, err)
+	}
+	if _, _, err = o.Depth(Bid, 25); err == nil {
+		t.Error("expected error for a resting side")
+	}
+	if _, err = o.EstimateFill(Bid, 1); err == nil {
+		t.Error("expected error for a resting side")
+	}
+
+	_, err = (&Orderbook{}).Spread()
+	if err == nil {
+		t.Error("expected error for empty book")
+	}
+}
+
+func TestEstimateFill(t *testing.T) {
+	o := testBook()
+
+	e, err := o.EstimateFill(Buy, 3.5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.Partial || e.Levels != 2 || e.WorstPrice != 10020 || !near(e.AvgPrice, (10010*3+10020*0.5)/3.5) {
+		t.Errorf("unexpected buy estimate: %+v", e)
+	}
+	if !near(e.Slippage, e.AvgPrice-10010) || e.SlippageBps <= 0 {
+		t.Errorf("unexpected buy slippage: %+v", e)
+	}
+
+	e, err = o.EstimateFill(Sell, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !e.Partial || e.Qty != 8 || e.WorstPrice != 9900 || e.Slippage <= 0 {
+		t.Errorf("unexpected sell estimate: %+v", e)
+	}
+
+	e, err = o.EstimateFillNotional(Sell, 9990+9980)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.Partial || !near(e.Qty, 2) || !near(e.Notional, 9990+9980) {
+		t.Errorf("unexpected notional estimate: %+v", e)
+	}
+}
+
+func TestAggregate(t *testing.T) {
+	o := testBook()
+	o.Asks[0].Orders, o.Asks[1].Orders = 2, 1
+
+	agg, err := o.Aggregate(50)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(agg.Bids) != 2 || agg.Bids[0] != (OrderbookOrder{Price: 9950, Qty: 3}) {
+		t.Errorf("unexpected bids: %+v", agg.Bids)
+	}
+	if len(agg.Asks) != 2 || agg.Asks[0] != (OrderbookOrder{Price: 10050, Qty: 4, Orders: 3}) ||
+		agg.Asks[1].Price != 10100 {
+		t.Errorf("unexpected asks: %+v", agg.Asks)
+	}
+}
@@ -0,0 +1,639 @@
+package korbit
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Trader is the order and account side of API. PaperExchange implements it too, so a
+// strategy written against Trader runs unchanged in paper mode.
+type Trader interface {
+	Buy(order *OrderArgs) (*OrderResponse, error)
+	Sell(order *OrderArgs) (*OrderResponse, error)
+	CancelOpenOrders(orders []int64, currency string) ([]CancelOrderResp, error)
+	ListOpenOrders(coin string) (*[]ListOrderResp, error)
+	GetBalances() (Balances, error)
+	GetTransactionHistory(coin, category, offset, limit, orderID string) (*[]TransactionsResponse, error)
+}
+
+var (
+	_ Trader = (*API)(nil)
+	_ Trader = (*PaperExchange)(nil)
+)
+
+// paperSlack is how close to zero a remaining amount has to be to count as nothing.
+const paperSlack = 1e-9
+
+// paperOrder is an order of a PaperExchange. Market buys have no price and a qty that
+// grows as they fill, they spend the reserved KRW instead.
+type paperOrder struct {
+	id       int64
+	pair     string
+	side     string // buy or sell
+	price    int64
+	qty      float64
+	open     float64
+	reserved float64 // KRW held for buys and coin held for sells
+	time     time.Time
+	activeAt time.Time // when the order reaches the book with latency
+	ahead    float64   // qty resting in front of the order at its price
+}
+
+// PaperExchange is a simulated exchange with virtual balances. Orders that cross the last
+// orderbook fill against it at the book prices and pay the taker fee, the rest of a limit
+// order rests and fills at its own price with the maker fee once an orderbook or a trade
+// from the market data reaches it. Market data is given with Apply or Feed, either live
+// from a MarketDataSource or recorded, and the time of the exchange follows it.
+//
+// With Latency set new orders only reach the book once the market data is that much later
+// than when they were placed, their funds are held in the meantime. With QueuePosition set
+// a resting order is behind the qty that was at its price when it arrived, so trades at
+// exactly its price have to get through that qty before filling it.
+type PaperExchange struct {
+	MakerFee      float64
+	TakerFee      float64
+	Latency       time.Duration
+	QueuePosition bool
+
+	mu        sync.Mutex
+	balances  Balances
+	books     map[string]*Orderbook
+	open      []*paperOrder // in the order they were placed
+	pending   []*paperOrder // placed but not on the book yet because of latency
+	closed    map[int64]string
+	fills     map[string][]TransactionsResponse // pair -> fills oldest first
+	lastOrder int64
+	lastFill  int64
+	clock     time.Time
+}
+
+// NewPaperExchange returns a paper exchange that starts with the balances. The fees are
+// fractions of the filled amount, like 0.0008 for 0.08%.
+func NewPaperExchange(balances Balances, makerFee, takerFee float64) *PaperExchange {
+	p := PaperExchange{
+		MakerFee: makerFee,
+		TakerFee: takerFee,
+		balances: Balances{},
+		books:    map[string]*Orderbook{},
+		closed:   map[int64]string{},
+		fills:    map[string][]TransactionsResponse{},
+	}
+	for c, b := range balances {
+		p.balances[c] = b
+	}
+	return &p
+}
+
+// Buy places a bid the way API.Buy does.
+func (p *PaperExchange) Buy(order *OrderArgs) (*OrderResponse, error) {
+	return p.place(Buy, order)
+}
+
+// Sell places an ask the way API.Sell does.
+func (p *PaperExchange) Sell(order *OrderArgs) (*OrderResponse, error) {
+	return p.place(Sell, order)
+}
+
+// place checks and reserves the funds for the order, fills what it can against the last
+// orderbook and rests the remainder of limit orders.
+func (p *PaperExchange) place(side string, order *OrderArgs) (*OrderResponse, error) {
+	if order.Type != Limit && order.Type != Market {
+		return nil, errors.New("unrecognized order type")
+	}
+
+	prec, err := PrecisionFor(order.CurrencyPair)
+	if err != nil {
+		return nil, err
+	}
+	coin, fiat := PairCurrencies(order.CurrencyPair)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	resp := OrderResponse{CurrencyPair: order.CurrencyPair, Side: side, Price: order.Price, Type: order.Type}
+	reject := func(status string) (*OrderResponse, error) {
+		resp.Status = status
+		return &resp, errors.Errorf("order not successful: %s", status)
+	}
+
+	o := paperOrder{pair: order.CurrencyPair, side: side, time: p.now()}
+
+	// market buys are sized by the KRW to spend and everything else by the coin amount.
+	if order.Type == Market && side == Buy {
+		budget, err := strconv.ParseFloat(order.FiatAmount, 64)
+		if err != nil || budget <= 0 {
+			return reject("invalid_amount")
+		}
+		if p.balances[fiat].Available < budget-paperSlack {
+			return reject("not_enough_" + fiat)
+		}
+		o.reserved = budget
+	} else {
+		qty, err := strconv.ParseFloat(order.CoinAmount, 64)
+		if err != nil {
+			return reject("invalid_amount")
+		}
+		o.qty = prec.FloorQty(qty)
+		o.open = o.qty
+		if o.qty <= 0 {
+			return reject("invalid_amount")
+		}
+
+		if order.Type == Limit {
+			if order.Price <= 0 || order.Price%prec.PriceTick != 0 {
+				return reject("invalid_price")
+			}
+			o.price = order.Price
+		}
+
+		switch {
+		case side == Sell && p.balances[coin].Available < o.qty-paperSlack:
+			return reject("not_enough_" + coin)
+		case side == Sell:
+			o.reserved = o.qty
+		case p.balances[fiat].Available < float64(o.price)*o.qty-paperSlack:
+			return reject("not_enough_" + fiat)
+		default:
+			o.reserved = float64(o.price) * o.qty
+		}
+	}
+
+	if order.Type == Market && p.books[o.pair] == nil {
+		return nil, errors.Errorf("no orderbook for %s to fill a market order against", o.pair)
+	}
+
+	held := fiat
+	if side == Sell {
+		held = coin
+	}
+	p.adjust(held, -o.reserved, o.reserved)
+
+	p.lastOrder++
+	o.id = p.lastOrder
+	if p.Latency > 0 {
+		o.activeAt = o.time.Add(p.Latency)
+		p.pending = append(p.pending, &o)
+	} else {
+		p.activate(&o)
+	}
+
+	resp.OrderID = o.id
+	resp.Status = Success
+	return &resp, nil
+}
+
+// activate puts the order on the book, it fills what it can as a taker and what is left
+// of a limit order rests.
+func (p *PaperExchange) activate(o *paperOrder) {
+	p.take(o)
+
+	switch {
+	case o.price == 0:
+		p.release(o)
+		p.closed[o.id] = "already_filled"
+	case o.open > paperSlack:
+		if p.QueuePosition {
+			o.ahead = p.levelQty(o)
+		}
+		p.open = append(p.open, o)
+	default:
+		p.closed[o.id] = "already_filled"
+	}
+}
+
+// arrive activates the pending orders whose latency is over at t, against the book as it
+// was before the market data of t.
+func (p *PaperExchange) arrive(t time.Time) {
+	var waiting []*paperOrder
+	for _, o := range p.pending {
+		if o.activeAt.After(t) {
+			waiting = append(waiting, o)
+			continue
+		}
+		p.advance(o.activeAt)
+		p.activate(o)
+	}
+	p.pending = waiting
+}
+
+// levelQty gives the qty of the last book at the price of the order on its side.
+func (p *PaperExchange) levelQty(o *paperOrder) float64 {
+	book := p.books[o.pair]
+	if book == nil {
+		return 0
+	}
+
+	levels := book.Bids
+	if o.side == Sell {
+		levels = book.Asks
+	}
+	for _, l := range levels {
+		if l.Price == o.price {
+			return l.Qty
+		}
+	}
+	return 0
+}
+
+// take fills the order against the last orderbook of its pair as a taker, removing the
+// liquidity it used from the book.
+func (p *PaperExchange) take(o *paperOrder) {
+	book := p.books[o.pair]
+	if book == nil {
+		return
+	}
+
+	levels := book.Asks
+	if o.side == Sell {
+		levels = book.Bids
+	}
+
+	for i := range levels {
+		l := &levels[i]
+		if o.price > 0 && (o.side == Buy && l.Price > o.price || o.side == Sell && l.Price < o.price) {
+			break
+		}
+		if l.Qty <= paperSlack {
+			continue
+		}
+
+		var q float64
+		switch {
+		case o.price == 0 && o.side == Buy:
+			prec, _ := PrecisionFor(o.pair)
+			q = math.Min(l.Qty, prec.FloorQty(o.reserved/float64(l.Price)))
+		default:
+			q = math.Min(l.Qty, o.open)
+		}
+		if q <= paperSlack {
+			break
+		}
+
+		p.fill(o, l.Price, q, p.TakerFee)
+		l.Qty -= q
+	}
+
+	compactBook(book)
+}
+
+// compactBook drops the levels of the book that were used up.
+func compactBook(book *Orderbook) {
+	keep := func(levels []OrderbookOrder) []OrderbookOrder {
+		kept := levels[:0]
+		for _, l := range levels {
+			if l.Qty > paperSlack {
+				kept = append(kept, l)
+			}
+		}
+		return kept
+	}
+	book.Bids = keep(book.Bids)
+	book.Asks = keep(book.Asks)
+}
+
+// fill moves the balances for q of the order filled at price and records the fill. The
+// fee is taken from what is received, coin for buys and KRW for sells.
+func (p *PaperExchange) fill(o *paperOrder, price int64, q, feeRate float64) {
+	coin, fiat := PairCurrencies(o.pair)
+	notional := float64(price) * q
+
+	var fee Currency
+	if o.side == Buy {
+		held := notional
+		if o.price > 0 {
+			held = float64(o.price) * q // a limit buy held its own price for the qty
+		}
+		o.reserved -= held
+		p.adjust(fiat, held-notional, -held)
+
+		fee = Currency{Currency: coin, Value: q * feeRate}
+		p.adjust(coin, q-fee.Value, 0)
+	} else {
+		o.reserved -= q
+		p.adjust(coin, 0, -q)
+
+		fee = Currency{Currency: fiat, Value: notional * feeRate}
+		p.adjust(fiat, notional-fee.Value, 0)
+	}
+
+	if o.price == 0 && o.side == Buy {
+		o.qty += q
+	} else {
+		o.open -= q
+	}
+
+	p.lastFill++
+	ts := p.now().UnixNano() / int64(time.Millisecond)
+	p.fills[o.pair] = append(p.fills[o.pair], TransactionsResponse{
+		Timestamp:   ts,
+		CompletedAt: ts,
+		ID:          p.lastFill,
+		Type:        o.side,
+		Fee:         fee,
+		Balances: []Currency{
+			{Currency: fiat, Value: p.balances[fiat].Available},
+			{Currency: coin, Value: p.balances[coin].Available},
+		},
+		FillsDetail: FillDetail{
+			Price:        Currency{Currency: fiat, Value: float64(price)},
+			Amount:       Currency{Currency: coin, Value: q},
+			NativeAmount: Currency{Currency: fiat, Value: notional},
+			OrderID:      o.id,
+		},
+	})
+}
+
+// release gives what the order still holds back to available.
+func (p *PaperExchange) release(o *paperOrder) {
+	coin, fiat := PairCurrencies(o.pair)
+	held := fiat
+	if o.side == Sell {
+		held = coin
+	}
+	p.adjust(held, o.reserved, -o.reserved)
+	o.reserved = 0
+}
+
+// adjust changes the available and in use balance of the currency.
+func (p *PaperExchange) adjust(currency string, available, inUse float64) {
+	b := p.balances[currency]
+	b.Available += available
+	b.TradeInuse += inUse
+	p.balances[currency] = b
+}
+
+// now is the time of the last market data, or the wall clock before there was any.
+func (p *PaperExchange) now() time.Time {
+	if p.clock.IsZero() {
+		return time.Now()
+	}
+	return p.clock
+}
+
+// Apply updates the exchange with a piece of market data. Orderbooks replace the last
+// book of the pair and trades are only used to fill resting orders.
+func (p *PaperExchange) Apply(e MarketEvent) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	t := e.Time()
+	if t.IsZero() {
+		return
+	}
+	p.arrive(t)
+	p.advance(t)
+
+	switch e.Type {
+	case OrderbookEvent:
+		book := *e.Orderbook
+		book.Bids = append([]OrderbookOrder(nil), book.Bids...)
+		book.Asks = append([]OrderbookOrder(nil), book.Asks...)
+		p.books[e.CurrencyPair] = &book
+
+		for _, o := range p.resting(e.CurrencyPair) {
+			// the qty in front can only shrink, whatever was added went behind.
+			if p.QueuePosition {
+				o.ahead = math.Min(o.ahead, p.levelQty(o))
+			}
+			p.makeFromBook(o, &book)
+		}
+		compactBook(&book)
+
+	case TradeEvent:
+		remaining := e.Trade.Amount
+		for _, o := range p.resting(e.CurrencyPair) {
+			if remaining <= paperSlack {
+				break
+			}
+			if o.side == Buy && o.price < e.Trade.Price || o.side == Sell && o.price > e.Trade.Price {
+				continue
+			}
+			if p.QueuePosition && o.price == e.Trade.Price {
+				queued := math.Min(o.ahead, remaining)
+				o.ahead -= queued
+				remaining -= queued
+			}
+
+			q := math.Min(o.open, remaining)
+			if q <= paperSlack {
+				continue
+			}
+			p.fill(o, o.price, q, p.MakerFee)
+			remaining -= q
+		}
+	}
+
+	p.prune()
+}
+
+// Feed applies the events until the channel is closed, like the events of a
+// MarketDataSource.
+func (p *PaperExchange) Feed(events <-chan MarketEvent) {
+	for e := range events {
+		p.Apply(e)
+	}
+}
+
+// advance moves the clock forward, it never goes back.
+func (p *PaperExchange) advance(t time.Time) {
+	if t.After(p.clock) {
+		p.clock = t
+	}
+}
+
+// resting gives the open orders of the pair, best price first and then oldest first.
+func (p *PaperExchange) resting(pair string) []*paperOrder {
+	var orders []*paperOrder
+	for _, o := range p.open {
+		if o.pair == pair {
+			orders = append(orders, o)
+		}
+	}
+
+	sort.SliceStable(orders, func(i, j int) bool {
+		a, b := orders[i], orders[j]
+		if a.side != b.side {
+			return a.side == Buy
+		}
+		if a.side == Buy {
+			return a.price > b.price
+		}
+		return a.price < b.price
+	})
+	return orders
+}
+
+// makeFromBook fills a resting order at its own price against the levels of the book
+// that crossed it.
+func (p *PaperExchange) makeFromBook(o *paperOrder, book *Orderbook) {
+	levels := book.Asks
+	if o.side == Sell {
+		levels = book.Bids
+	}
+
+	for i := range levels {
+		l := &levels[i]
+		if o.open <= paperSlack || o.side == Buy && l.Price > o.price || o.side == Sell && l.Price < o.price {
+			break
+		}
+		if l.Qty <= paperSlack {
+			continue
+		}
+		q := math.Min(l.Qty, o.open)
+		p.fill(o, o.price, q, p.MakerFee)
+		l.Qty -= q
+	}
+}
+
+// prune closes the orders that filled completely.
+func (p *PaperExchange) prune() {
+	open := p.open[:0]
+	for _, o := range p.open {
+		if o.open > paperSlack {
+			open = append(open, o)
+			continue
+		}
+		p.release(o)
+		p.closed[o.id] = "already_filled"
+	}
+	p.open = open
+}
+
+// CancelOpenOrders cancels the open orders the way API.CancelOpenOrders does, giving a
+// status for every id.
+func (p *PaperExchange) CancelOpenOrders(orders []int64, currency string) ([]CancelOrderResp, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var resps []CancelOrderResp
+	for _, id := range orders {
+		resp := CancelOrderResp{OrderID: id, CurrencyPair: currency, Status: "not_found"}
+		if status, ok := p.closed[id]; ok {
+			resp.Status = status
+		}
+
+		if p.cancel(&p.open, id, currency) || p.cancel(&p.pending, id, currency) {
+			resp.Status = Success
+		}
+
+		resps = append(resps, resp)
+	}
+
+	return resps, nil
+}
+
+// cancel removes the order from the list and releases its funds, reporting whether it
+// was there.
+func (p *PaperExchange) cancel(orders *[]*paperOrder, id int64, pair string) bool {
+	for i, o := range *orders {
+		if o.id != id || o.pair != pair {
+			continue
+		}
+		p.release(o)
+		*orders = append((*orders)[:i], (*orders)[i+1:]...)
+		p.closed[id] = "already_canceled"
+		return true
+	}
+	return false
+}
+
+// ListOpenOrders lists the resting orders of the pair oldest first, followed by the ones
+// that are still on their way because of latency.
+func (p *PaperExchange) ListOpenOrders(coin string) (*[]ListOrderResp, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	c, fiat := PairCurrencies(coin)
+	orders := []ListOrderResp{}
+	for _, o := range append(append([]*paperOrder(nil), p.open...), p.pending...) {
+		if o.pair != coin {
+			continue
+		}
+
+		side := Bid
+		if o.side == Sell {
+			side = Ask
+		}
+		orders = append(orders, ListOrderResp{
+			Timestamp: o.time.UnixNano() / int64(time.Millisecond),
+			ID:        o.id,
+			Type:      side,
+			Price:     Currency{Currency: fiat, Value: float64(o.price)},
+			Total:     Currency{Currency: c, Value: o.qty},
+			Open:      Currency{Currency: c, Value: o.open},
+		})
+	}
+
+	return &orders, nil
+}
+
+// GetBalances gives a copy of the virtual balances.
+func (p *PaperExchange) GetBalances() (Balances, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	balances := Balances{}
+	for c, b := range p.balances {
+		balances[c] = b
+	}
+	return balances, nil
+}
+
+// GetTransactionHistory gives the fills of the pair newest first, paged the way korbit
+// pages them. Paper exchanges have no transfers so the other categories are empty.
+func (p *PaperExchange) GetTransactionHistory(coin, category, offset, limit, orderID string) (
+	*[]TransactionsResponse, error) {
+
+	if coin == "" {
+		return nil, errors.New("coin must be specified")
+	}
+	if category == "" {
+		return nil, errors.New("category must be one of 'fills' 'fiats' or 'coins'")
+	}
+
+	from, size := 0, -1
+	var err error
+	if offset != "" {
+		from, err = strconv.Atoi(offset)
+		if err != nil {
+			return nil, errors.Wrapf(err, "offset %s", offset)
+		}
+	}
+	if limit != "" {
+		size, err = strconv.Atoi(limit)
+		if err != nil {
+			return nil, errors.Wrapf(err, "limit %s", limit)
+		}
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	history := []TransactionsResponse{}
+	if category != Fills {
+		return &history, nil
+	}
+
+	fills := p.fills[coin]
+	for i := len(fills) - 1; i >= 0; i-- {
+		if orderID != "" && strconv.FormatInt(fills[i].FillsDetail.OrderID, 10) != orderID {
+			continue
+		}
+		history = append(history, fills[i])
+	}
+
+	if from > len(history) {
+		from = len(history)
+	}
+	history = history[from:]
+	if size >= 0 && size < len(history) {
+		history = history[:size]
+	}
+
+	return &history, nil
+}
@@ -0,0 +1,132 @@
+package korbit
+
+import (
+	"math"
+	"testing"
+)
+
+func paperBook(ts int64) MarketEvent {
+	return MarketEvent{
+		Type:         OrderbookEvent,
+		CurrencyPair: BTCKRW,
+		Orderbook: &Orderbook{
+			CurrencyPair: BTCKRW,
+			Timestamp:    ts,
+			Bids:         []OrderbookOrder{{Price: 3000000, Qty: 0.5}, {Price: 2999500, Qty: 1}},
+			Asks:         []OrderbookOrder{{Price: 3000500, Qty: 0.2}, {Price: 3001000, Qty: 1}},
+		},
+	}
+}
+
+func TestPaperTakerFillsAndRests(t *testing.T) {
+	p := NewPaperExchange(Balances{KRW: {Available: 10000000}}, 0, 0.001)
+	p.Apply(paperBook(1500000000000))
+
+	// crosses the first ask level and rests the remainder at its limit.
+	resp, err := p.Buy(&OrderArgs{CurrencyPair: BTCKRW, Type: Limit, Price: 3000500, CoinAmount: "0.5"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, _ := p.GetBalances()
+	if !near(b[BTC].Available, 0.2*0.999) {
+		t.Errorf("unexpected btc: %+v", b[BTC])
+	}
+	if !near(b[KRW].TradeInuse, 3000500*0.3) || !near(b[KRW].Available, 10000000-3000500*0.5) {
+		t.Errorf("unexpected krw: %+v", b[KRW])
+	}
+
+	orders, _ := p.ListOpenOrders(BTCKRW)
+	if len(*orders) != 1 || (*orders)[0].ID != resp.OrderID || !near((*orders)[0].Open.Value, 0.3) ||
+		(*orders)[0].Type != Bid {
+		t.Errorf("unexpected open orders: %+v", *orders)
+	}
+
+	cancels, _ := p.CancelOpenOrders([]int64{resp.OrderID, 99}, BTCKRW)
+	if cancels[0].Status != Success || cancels[1].Status != "not_found" {
+		t.Errorf("unexpected cancels: %+v", cancels)
+	}
+
+	b, _ = p.GetBalances()
+	if !near(b[KRW].TradeInuse, 0) || !near(b[KRW].Available, 10000000-3000500*0.2) {
+		t.Errorf("unexpected krw after cancel: %+v", b[KRW])
+	}
+}
+
+func TestPaperMakerFills(t *testing.T) {
+	p := NewPaperExchange(Balances{BTC: {Available: 1}}, 0.0005, 0.001)
+	p.Apply(paperBook(1500000000000))
+
+	resp, err := p.Sell(&OrderArgs{CurrencyPair: BTCKRW, Type: Limit, Price: 3002000, CoinAmount: "0.6"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a trade at the price fills part of the order and a book crossing it fills the rest.
+	p.Apply(MarketEvent{Type: TradeEvent, CurrencyPair: BTCKRW,
+		Trade: &Trade{Timestamp: 1500000001000, TID: 1, Price: 3002000, Amount: 0.25}})
+
+	book := paperBook(1500000002000)
+	book.Orderbook.Bids = append([]OrderbookOrder{{Price: 3002500, Qty: 1}}, book.Orderbook.Bids...)
+	p.Apply(book)
+
+	orders, _ := p.ListOpenOrders(BTCKRW)
+	if len(*orders) != 0 {
+		t.Errorf("expected the order to be filled: %+v", *orders)
+	}
+
+	b, _ := p.GetBalances()
+	if !near(b[BTC].Available, 0.4) || !near(b[BTC].TradeInuse, 0) ||
+		!near(b[KRW].Available, 3002000*0.6*(1-0.0005)) {
+		t.Errorf("unexpected balances: %+v", b)
+	}
+
+	history, err := p.GetTransactionHistory(BTCKRW, Fills, "", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(*history) != 2 || (*history)[0].ID != 2 || !near((*history)[0].FillsDetail.Amount.Value, 0.35) ||
+		(*history)[0].FillsDetail.Price.Value != 3002000 || (*history)[0].Timestamp != 1500000002000 {
+		t.Errorf("unexpected history: %+v", *history)
+	}
+
+	page, _ := p.GetTransactionHistory(BTCKRW, Fills, "1", "5", "")
+	if len(*page) != 1 || (*page)[0].ID != 1 {
+		t.Errorf("unexpected page: %+v", *page)
+	}
+
+	cancels, _ := p.CancelOpenOrders([]int64{resp.OrderID}, BTCKRW)
+	if cancels[0].Status != "already_filled" {
+		t.Errorf("unexpected cancel: %+v", cancels)
+	}
+}
+
+func TestPaperMarketAndRejects(t *testing.T) {
+	p := NewPaperExchange(Balances{KRW: {Available: 1000000}}, 0, 0)
+
+	_, err := p.Buy(&OrderArgs{CurrencyPair: BTCKRW, Type: Market, FiatAmount: "700000"})
+	if err == nil {
+		t.Error("expected error for a market order without a book")
+	}
+
+	p.Apply(paperBook(1500000000000))
+	_, err = p.Buy(&OrderArgs{CurrencyPair: BTCKRW, Type: Market, FiatAmount: "700000"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, _ := p.GetBalances()
+	want := 0.2 + math.Floor((700000-3000500*0.2)/3001000*1e8)/1e8
+	if !near(b[BTC].Available, want) || !near(b[KRW].TradeInuse, 0) {
+		t.Errorf("unexpected balances: %+v", b)
+	}
+
+	resp, err := p.Buy(&OrderArgs{CurrencyPair: BTCKRW, Type: Limit, Price: 2000000, CoinAmount: "1"})
+	if err == nil || resp.Status != "not_enough_krw" {
+		t.Errorf("unexpected reject: %+v %v", resp, err)
+	}
+	resp, err = p.Sell(&OrderArgs{CurrencyPair: BTCKRW, Type: Limit, Price: 3000100, CoinAmount: "0.1"})
+	if err == nil || resp.Status != "invalid_price" {
+		t.Errorf("unexpected reject: %+v %v", resp, err)
+	}
+}
@@ -0,0 +1,174 @@
+package korbit
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// MarkBid and the other marks here are the prices that holdings can be valued at.
+const (
+	MarkBid  = "bid"
+	MarkMid  = "mid"
+	MarkLast = "last"
+)
+
+// Holding is the valuation of a single currency. The amounts are in the currency and the
+// KRW fields are the amounts at the mark price.
+type Holding struct {
+	Currency          string
+	Available         float64
+	InOrders          float64
+	PendingWithdrawal float64
+	Total             float64
+
+	Mark                 float64 // KRW per unit
+	AvailableKRW         float64
+	InOrdersKRW          float64
+	PendingWithdrawalKRW float64
+	ValueKRW             float64
+	Allocation           float64 // percent of the portfolio value
+
+	// LiquidationKRW is the KRW from selling what is not being withdrawn into the bids.
+	// When the orderbook can not be fetched it is taken at the best bid of the ticker.
+	LiquidationKRW     float64
+	LiquidationPartial bool // the bids are not deep enough to sell it all
+
+	// Missing says why the holding could not be fully valued. Without a mark price the
+	// holding is left out of the portfolio totals.
+	Missing string
+}
+
+// Portfolio is the KRW valuation of every holding of the account.
+type Portfolio struct {
+	Mark           string
+	Holdings       map[string]*Holding
+	ValueKRW       float64
+	LiquidationKRW float64
+	Time           time.Time
+}
+
+// markPrice gives the price of the ticker for the mark, zero when the ticker does not
+// have it.
+func markPrice(p *Prices, mark string) (float64, error) {
+	switch mark {
+	case MarkBid:
+		return float64(p.Bid), nil
+	case MarkMid:
+		if p.Bid == 0 || p.Ask == 0 {
+			return 0, nil
+		}
+		return float64(p.Bid+p.Ask) / 2, nil
+	case MarkLast:
+		return float64(p.Last), nil
+	}
+	return 0, errors.Errorf("unrecognized mark: %s", mark)
+}
+
+// Portfolio values the balances of the account in KRW at the mark, which is one of bid,
+// mid or last. The liquidation value walks the orderbook of every coin that is held. A
+// coin that can not be valued does not fail the rest, its holding says what is missing.
+func (k *API) Portfolio(mark string) (*Portfolio, error) {
+	_, err := markPrice(&Prices{}, mark)
+	if err != nil {
+		return nil, err
+	}
+
+	balances, err := k.GetBalances()
+	if err != nil {
+		return nil, errors.Wrap(err, "portfolio balances")
+	}
+
+	tickers, err := k.GetAllTickers()
+	if err != nil {
+		return nil, errors.Wrap(err, "portfolio tickers")
+	}
+
+	ret := Portfolio{
+		Mark:     mark,
+		Holdings: map[string]*Holding{},
+		Time:     time.Now(),
+	}
+
+	for currency, b := range balances {
+		h := Holding{
+			Currency:          currency,
+			Available:         b.Available,
+			InOrders:          b.TradeInuse,
+			PendingWithdrawal: b.WithdrawalInUse,
+			Total:             b.Available + b.TradeInuse + b.WithdrawalInUse,
+		}
+		if h.Total <= 0 {
+			continue
+		}
+
+		// what is being withdrawn can not be sold.
+		sellable := h.Available + h.InOrders
+
+		if currency == KRW {
+			h.Mark = 1
+			h.LiquidationKRW = sellable
+		} else {
+			k.valueCoin(&h, tickers, mark, sellable)
+		}
+
+		ret.Holdings[currency] = &h
+		if h.Mark <= 0 {
+			continue
+		}
+
+		h.AvailableKRW = h.Available * h.Mark
+		h.InOrdersKRW = h.InOrders * h.Mark
+		h.PendingWithdrawalKRW = h.PendingWithdrawal * h.Mark
+		h.ValueKRW = h.Total * h.Mark
+
+		ret.ValueKRW += h.ValueKRW
+		ret.LiquidationKRW += h.LiquidationKRW
+	}
+
+	for _, h := range ret.Holdings {
+		if ret.ValueKRW > 0 {
+			h.Allocation = h.ValueKRW / ret.ValueKRW * 100
+		}
+	}
+
+	return &ret, nil
+}
+
+// valueCoin sets the mark and the liquidation value of a coin holding, or what is missing
+// for them.
+func (k *API) valueCoin(h *Holding, tickers map[string]*Prices, mark string, sellable float64) {
+	pair := h.Currency + "_" + KRW
+	ticker, ok := tickers[pair]
+	if !ok {
+		h.Missing = "no ticker for " + pair
+		return
+	}
+
+	h.Mark, _ = markPrice(ticker, mark)
+	if h.Mark <= 0 {
+		h.Missing = "no " + mark + " price for " + pair
+		return
+	}
+
+	if sellable <= 0 {
+		return
+	}
+
+	book, err := k.GetOrderbook(pair)
+	if err != nil {
+		// the coin is in the value so it is kept in the liquidation total too.
+		h.Missing = errors.Wrapf(err, "orderbook for %s, liquidated at the ticker bid", pair).Error()
+		h.LiquidationKRW = sellable * float64(ticker.Bid)
+		return
+	}
+
+	fill, err := book.EstimateFill(Sell, sellable)
+	if err != nil {
+		h.Missing = "no bids for " + pair
+		h.LiquidationPartial = true
+		return
+	}
+	h.LiquidationKRW = fill.Notional
+	h.LiquidationPartial = fill.Partial
+}
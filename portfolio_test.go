@@ -0,0 +1,85 @@
+package korbit
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestPortfolio(t *testing.T) {
+	newTestAPI(t, &BalancesURL, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{
+			"krw": {"available": "500000", "trade_in_use": "500000", "withdrawal_in_use": "0"},
+			"btc": {"available": "0.1", "trade_in_use": "0", "withdrawal_in_use": "0.1"},
+			"eth": {"available": "0", "trade_in_use": "0", "withdrawal_in_use": "0"},
+			"etc": {"available": "3", "trade_in_use": "0", "withdrawal_in_use": "0"},
+			"xrp": {"available": "100", "trade_in_use": "0", "withdrawal_in_use": "0"},
+			"bch": {"available": "2", "trade_in_use": "0", "withdrawal_in_use": "0"}
+		}`)
+	})
+	newTestAPI(t, &AllTickers, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"btc_krw": {"timestamp": 1, "last": "10000000", "bid": "9990000", "ask": "10010000"},
+			"xrp_krw": {"timestamp": 1, "last": "500", "bid": "499", "ask": "501"},
+			"bch_krw": {"timestamp": 1, "last": "301000", "bid": "300000", "ask": "302000"}}`)
+	})
+	k := newTestAPI(t, &GetOrderbook, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("currency_pair") == "bch_krw" {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		if r.URL.Query().Get("currency_pair") == XRPKRW {
+			fmt.Fprint(w, `{"timestamp": 1, "bids": [], "asks": [["501", "1000", "1"]]}`)
+			return
+		}
+		if r.URL.Query().Get("currency_pair") != BTCKRW {
+			t.Errorf("unexpected orderbook request: %s", r.URL.RawQuery)
+		}
+		fmt.Fprint(w, `{"timestamp": 1, "bids": [["9990000", "0.1", "1"], ["9900000", "1", "1"]],
+			"asks": [["10010000", "1", "1"]]}`)
+	})
+
+	p, err := k.Portfolio(MarkMid)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(p.Holdings) != 5 || p.Holdings[ETH] != nil {
+		t.Fatalf("unexpected holdings: %v", p.Holdings)
+	}
+
+	// a coin without a ticker is reported and left out, one without bids is still valued.
+	if etc := p.Holdings[ETC]; etc.Missing == "" || etc.ValueKRW != 0 {
+		t.Errorf("unexpected etc holding: %+v", etc)
+	}
+	if xrp := p.Holdings[XRP]; xrp.Missing == "" || xrp.ValueKRW != 50000 || !xrp.LiquidationPartial {
+		t.Errorf("unexpected xrp holding: %+v", xrp)
+	}
+	// a coin without an orderbook is liquidated at the ticker bid.
+	if bch := p.Holdings["bch"]; bch.Missing == "" || bch.ValueKRW != 602000 || bch.LiquidationKRW != 600000 {
+		t.Errorf("unexpected bch holding: %+v", bch)
+	}
+
+	btc := p.Holdings[BTC]
+	if btc.Mark != 10000000 || btc.ValueKRW != 2000000 || btc.PendingWithdrawalKRW != 1000000 {
+		t.Errorf("unexpected btc holding: %+v", btc)
+	}
+	// the btc being withdrawn is not sold.
+	if !near(btc.LiquidationKRW, 999000) || btc.LiquidationPartial {
+		t.Errorf("unexpected btc liquidation: %+v", btc)
+	}
+
+	krw := p.Holdings[KRW]
+	if krw.ValueKRW != 1000000 || krw.InOrdersKRW != 500000 {
+		t.Errorf("unexpected krw holding: %+v", krw)
+	}
+
+	if p.ValueKRW != 3652000 || !near(btc.Allocation, 2000000.0/3652000*100) ||
+		!near(p.LiquidationKRW, 1000000+999000+600000) {
+		t.Errorf("unexpected totals: %v %v", p.ValueKRW, btc.Allocation)
+	}
+
+	_, err = k.Portfolio("vwap")
+	if err == nil {
+		t.Error("expected error for unknown mark")
+	}
+}
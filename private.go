@@ -0,0 +1,230 @@
+package korbit
+
+import (
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// MyOrderChannel and MyFillChannel are the private websocket channels for the orders and
+// fills of the logged in account.
+const (
+	MyOrderChannel = "myOrder"
+	MyFillChannel  = "myTransaction"
+)
+
+// OrderUpdate is pushed when one of the account's own orders is placed, filled or
+// canceled.
+type OrderUpdate struct {
+	CurrencyPair string  `json:"currency_pair"`
+	Timestamp    int64   `json:"timestamp"`
+	OrderID      int64   `json:"order_id,string"`
+	Side         string  `json:"side"`
+	Status       string  `json:"status"`
+	Price        int64   `json:"price,string"`
+	Amount       float64 `json:"amount,string"`
+	Filled       float64 `json:"filled_amount,string"`
+}
+
+// OwnFill is a fill of one of the account's own orders. Backfilled is set for the fills
+// that were missed while disconnected and recovered from the transaction history.
+type OwnFill struct {
+	CurrencyPair string
+	Backfilled   bool
+	TransactionsResponse
+}
+
+// wsFill is the data of a pushed fill.
+type wsFill struct {
+	CurrencyPair string  `json:"currency_pair"`
+	Timestamp    int64   `json:"timestamp"`
+	ID           int64   `json:"id,string"`
+	OrderID      int64   `json:"order_id,string"`
+	Side         string  `json:"side"`
+	Price        float64 `json:"price,string"`
+	Amount       float64 `json:"amount,string"`
+	Fee          float64 `json:"fee,string"`
+	FeeCurrency  string  `json:"fee_currency"`
+}
+
+// fill turns the pushed fill into the same shape as the transaction history has it.
+func (f *wsFill) fill() OwnFill {
+	coin, fiat := PairCurrencies(f.CurrencyPair)
+
+	return OwnFill{
+		CurrencyPair: f.CurrencyPair,
+		TransactionsResponse: TransactionsResponse{
+			Timestamp:   f.Timestamp,
+			CompletedAt: f.Timestamp,
+			ID:          f.ID,
+			Type:        f.Side,
+			Fee:         Currency{Currency: f.FeeCurrency, Value: f.Fee},
+			FillsDetail: FillDetail{
+				Price:        Currency{Currency: fiat, Value: f.Price},
+				Amount:       Currency{Currency: coin, Value: f.Amount},
+				NativeAmount: Currency{Currency: fiat, Value: f.Price * f.Amount},
+				OrderID:      f.OrderID,
+			},
+		},
+	}
+}
+
+// SubscribePrivate subscribes to the order updates and fills of the account for the
+// pairs, which needs the api to be logged in. Fills that are missed while the stream is
+// reconnecting are recovered from the transaction history.
+func (s *Stream) SubscribePrivate(pairs ...string) error {
+	if s.api == nil || s.api.CurrentToken() == nil {
+		return errors.New("login before subscribing to private channels")
+	}
+
+	err := s.Subscribe(MyOrderChannel, pairs...)
+	if err != nil {
+		return err
+	}
+	return s.Subscribe(MyFillChannel, pairs...)
+}
+
+// dispatchPrivate decodes a message of a private channel and delivers it.
+func (s *Stream) dispatchPrivate(channel string, data []byte) error {
+	switch channel {
+	case MyOrderChannel:
+		var update OrderUpdate
+		err := json.Unmarshal(data, &update)
+		if err != nil {
+			return errors.Wrap(err, "decode korbit order push")
+		}
+
+		select {
+		case s.Orders <- update:
+		case <-s.stop:
+		}
+
+	case MyFillChannel:
+		var push wsFill
+		err := json.Unmarshal(data, &push)
+		if err != nil {
+			return errors.Wrap(err, "decode korbit fill push")
+		}
+		s.deliverFill(push.fill())
+	}
+
+	return nil
+}
+
+// deliverFill sends the fill unless one with the same or a later id was already sent,
+// which happens when a backfill and a push overlap.
+func (s *Stream) deliverFill(f OwnFill) {
+	s.mu.Lock()
+	if f.ID <= s.lastFill[f.CurrencyPair] {
+		s.mu.Unlock()
+		return
+	}
+	s.lastFill[f.CurrencyPair] = f.ID
+	s.mu.Unlock()
+
+	select {
+	case s.Fills <- f:
+	case <-s.stop:
+	}
+}
+
+// hasPrivate reports whether any private channel is subscribed. It must be called with
+// the lock held.
+func (s *Stream) hasPrivate() bool {
+	return len(s.channels[MyOrderChannel]) > 0 || len(s.channels[MyFillChannel]) > 0
+}
+
+// reauth refreshes the token while private channels are subscribed and sends the
+// subscriptions again whenever the token changed, until quit is closed.
+func (s *Stream) reauth(quit chan struct{}) {
+	ticker := time.NewTicker(s.AuthCheck)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-quit:
+			return
+		case <-ticker.C:
+		}
+
+		s.mu.Lock()
+		active := s.conn != nil && s.hasPrivate()
+		s.mu.Unlock()
+		if !active || s.api.CurrentToken() == nil {
+			continue
+		}
+
+		// the refresh is a request of its own so it is done without holding the lock.
+		var err error
+		if s.api.ShouldRefresh() {
+			err = s.api.RefreshToken()
+		}
+
+		if err == nil {
+			s.mu.Lock()
+			if s.conn != nil && s.api.CurrentToken().AccessToken != s.sentToken {
+				err = s.send(s.conn, "korbit:subscribe", s.subscriptions())
+			}
+			s.mu.Unlock()
+		}
+
+		if err != nil {
+			s.report(errors.Wrap(err, "korbit stream reauth"))
+		}
+	}
+}
+
+// backfill delivers the fills that happened since the newest one delivered for each pair,
+// or since the pair was subscribed to when none was delivered yet, oldest first. Nothing
+// is delivered for a pair whose history could not be read in full, since the fills that
+// are missing would be skipped for good, it is tried again on the next reconnect.
+func (s *Stream) backfill() {
+	s.mu.Lock()
+	last := map[string]int64{}
+	from := map[string]time.Time{}
+	for pair := range s.channels[MyFillChannel] {
+		last[pair] = s.lastFill[pair]
+		from[pair] = s.fillsFrom[pair]
+	}
+	s.mu.Unlock()
+
+	for pair, lastID := range last {
+		missed, err := s.missedFills(pair, lastID, from[pair])
+		if err != nil {
+			s.report(errors.Wrapf(err, "korbit stream backfill for %s", pair))
+			continue
+		}
+
+		for i := len(missed) - 1; i >= 0; i-- {
+			s.deliverFill(OwnFill{CurrencyPair: pair, Backfilled: true, TransactionsResponse: missed[i]})
+		}
+	}
+}
+
+// missedFills pages through the fills of the pair, newest first, until the one with
+// lastID, or until the first one made before from when lastID is 0.
+func (s *Stream) missedFills(pair string, lastID int64, from time.Time) ([]TransactionsResponse, error) {
+	const pageSize = 100
+
+	fromMillis := from.UnixNano() / int64(time.Millisecond)
+	var missed []TransactionsResponse
+	for offset := 0; ; offset += pageSize {
+		page, err := s.api.GetTransactionHistory(pair, Fills, strconv.Itoa(offset),
+			strconv.Itoa(pageSize), "")
+		if err != nil {
+			return nil, err
+		}
+
+		for _, t := range *page {
+			if (lastID > 0 && t.ID <= lastID) || (lastID == 0 && t.Timestamp < fromMillis) {
+				return missed, nil
+			}
+			missed = append(missed, t)
+		}
+		if len(*page) < pageSize {
+			return missed, nil
+		}
+	}
+}
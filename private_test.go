@@ -0,0 +1,144 @@
+package korbit
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestPrivateStream(t *testing.T) {
+	// the fills 3 and 4 happen while the stream is disconnected.
+	history := []map[string]interface{}{fill(1), fill(2), fill(3), fill(4)}
+	k := newTestAPI(t, &TransactionHistory, historyHandler(t, &history))
+
+	connections := 0
+	tokens := make(chan string, 4)
+	url := newStreamServer(t, func(conn *websocket.Conn, channels []string) {
+		connections++
+		if connections > 1 {
+			// stay connected so that the backfill is the only way to get the fills.
+			conn.ReadMessage()
+			return
+		}
+
+		push(conn, MyOrderChannel, `"order_id": "77", "side": "buy", "status": "filled",
+			"price": "300000", "amount": "1.5", "filled_amount": "1.5"`)
+		push(conn, MyFillChannel, `"id": "2", "order_id": "77", "side": "buy", "price": "300000",
+			"amount": "1.5", "fee": "0.001", "fee_currency": "btc"`)
+
+		// the token is refreshed while connected which sends the subscription again.
+		var sub struct {
+			AccessToken string `json:"accessToken"`
+		}
+		conn.ReadJSON(&sub)
+		tokens <- sub.AccessToken
+	})
+
+	newTestAPI(t, &LoginURL, func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(Token{AccessToken: "refreshed", TokenType: "Bearer", ExpiresIn: 3600})
+	})
+	k.Token.ExpiresIn = 60 // less than 10 minutes left so it is refreshed straight away
+
+	s := NewStream(k)
+	s.URL = url
+	s.MinBackoff = 10 * time.Millisecond
+	s.AuthCheck = 10 * time.Millisecond
+	err := s.SubscribePrivate(BTCKRW)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Start()
+	defer s.Close()
+
+	order := <-s.Orders
+	if order.OrderID != 77 || order.Status != "filled" || order.Filled != 1.5 {
+		t.Errorf("unexpected order update: %+v", order)
+	}
+
+	f := <-s.Fills
+	if f.ID != 2 || f.Backfilled || f.FillsDetail.NativeAmount.Value != 450000 {
+		t.Errorf("unexpected fill: %+v", f)
+	}
+
+	select {
+	case token := <-tokens:
+		if token != "refreshed" {
+			t.Errorf("subscribed again with %s", token)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no subscription after the token refresh")
+	}
+
+	for _, want := range []int64{3, 4} {
+		select {
+		case f := <-s.Fills:
+			if f.ID != want || !f.Backfilled {
+				t.Errorf("got fill %d backfilled %v, want %d", f.ID, f.Backfilled, want)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("fill %d was not backfilled", want)
+		}
+	}
+}
+
+func TestBackfillFailedPage(t *testing.T) {
+	// more fills than fit on a page were missed and the older page fails at first.
+	var history []map[string]interface{}
+	for id := int64(1); id <= 150; id++ {
+		history = append(history, fill(id))
+	}
+	serve := historyHandler(t, &history)
+	fail := true
+	k := newTestAPI(t, &TransactionHistory, func(w http.ResponseWriter, r *http.Request) {
+		if fail && r.URL.Query().Get("offset") != "0" {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		serve(w, r)
+	})
+
+	s := NewStream(k)
+	s.Fills = make(chan OwnFill, len(history))
+	s.Subscribe(MyFillChannel, ETHKRW)
+	s.lastFill[ETHKRW] = 1
+
+	s.backfill()
+	if len(s.Fills) != 0 || s.lastFill[ETHKRW] != 1 {
+		t.Fatalf("partial backfill delivered %d fills", len(s.Fills))
+	}
+	if len(s.Errors) != 1 {
+		t.Error("failed backfill was not reported")
+	}
+
+	fail = false
+	s.backfill()
+	if len(s.Fills) != 149 {
+		t.Fatalf("got %d fills on retry, want 149", len(s.Fills))
+	}
+	if f := <-s.Fills; f.ID != 2 {
+		t.Errorf("first backfilled fill is %d, want 2", f.ID)
+	}
+}
+
+func TestBackfillBeforeFirstFill(t *testing.T) {
+	// the fills 2 and 3 are made after subscribing but before any fill was pushed.
+	history := []map[string]interface{}{fill(1), fill(2), fill(3)}
+	k := newTestAPI(t, &TransactionHistory, historyHandler(t, &history))
+
+	s := NewStream(k)
+	s.Subscribe(MyFillChannel, ETHKRW)
+	s.fillsFrom[ETHKRW] = time.Unix(0, 1500000000002*int64(time.Millisecond))
+
+	s.backfill()
+	for _, want := range []int64{2, 3} {
+		if f := <-s.Fills; f.ID != want || !f.Backfilled {
+			t.Errorf("got fill %d backfilled %v, want %d", f.ID, f.Backfilled, want)
+		}
+	}
+	if len(s.Fills) != 0 {
+		t.Errorf("%d fills made before subscribing were delivered", len(s.Fills))
+	}
+}
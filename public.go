@@ -10,22 +10,43 @@ import (
 	"github.com/pkg/errors"
 )
 
-// Prices models the JSON that is returned by the  API
+// Prices models the JSON that is returned by the  API. TimestampMillis is the unix
+// millisecond timestamp that korbit sends and Timestamp is the same instant in the
+// location of the API.
 type Prices struct {
-	Timestamp    *time.Time
-	TimestampStr int64   `json:"timestamp"`
-	Last         int64   `json:"last,string"`
-	Bid          int64   `json:"bid,string"`
-	Ask          int64   `json:"ask,string"`
-	Low          int64   `json:"low,string"`
-	High         int64   `json:"high,string"`
-	Volume       float64 `json:"volume,string"`
+	CurrencyPair    string
+	Timestamp       time.Time `json:"-"`
+	TimestampMillis int64     `json:"timestamp"`
+	Last            int64     `json:"last,string"`
+	Open            int64     `json:"open,string"`
+	Bid             int64     `json:"bid,string"`
+	Ask             int64     `json:"ask,string"`
+	Low             int64     `json:"low,string"`
+	High            int64     `json:"high,string"`
+	Volume          float64   `json:"volume,string"`
+	Change          int64     `json:"change,string"`
+	ChangePercent   float64   `json:"changePercent,string"`
+}
+
+// setTimestamp fills in Timestamp from the millisecond timestamp korbit sends.
+func (p *Prices) setTimestamp(loc *time.Location) {
+	p.Timestamp = millisToTime(p.TimestampMillis).In(loc)
+}
+
+// Age gives how old the quote is compared to the local clock.
+func (p *Prices) Age() time.Duration {
+	return time.Since(p.Timestamp)
+}
+
+// IsStale reports whether the quote is older than maxAge.
+func (p *Prices) IsStale(maxAge time.Duration) bool {
+	return p.Age() > maxAge
 }
 
 // GetPrices hits the  server to get the current prices
 func (k *API) GetPrices(coin string) (*Prices, error) {
 	// korbitCalls are the abbreviations for the coins on korbit
-	URL := fmt.Sprintf("https://api.korbit.co.kr/v1/ticker/detailed?currency_pair=%s", coin)
+	URL := fmt.Sprintf("%s?currency_pair=%s", DetailedTicker, coin)
 
 	req, err := k.NewRequest(URL, "GET", nil)
 	if err != nil {
@@ -48,9 +69,44 @@ func (k *API) GetPrices(coin string) (*Prices, error) {
 		return nil, errors.Wrapf(err, "decoding korbit bytes at %s", coin)
 	}
 
+	prices.CurrencyPair = coin
+	prices.setTimestamp(k.location())
+
 	return &prices, nil
 }
 
+// GetAllTickers gets the detailed ticker of every pair in a single request, keyed by the
+// currency pair.
+func (k *API) GetAllTickers() (map[string]*Prices, error) {
+	req, err := k.NewRequest(AllTickers, "GET", nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "korbit get all tickers")
+	}
+
+	resp, err := k.Client.Do(req)
+	if err != nil {
+		return nil, errors.Wrap(err, "korbit all tickers fetch")
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("status: %d Header: %v", resp.StatusCode, resp.Header)
+	}
+
+	var tickers map[string]*Prices
+	err = json.NewDecoder(resp.Body).Decode(&tickers)
+	if err != nil {
+		return nil, errors.Wrap(err, "korbit all tickers json decode")
+	}
+
+	for pair, prices := range tickers {
+		prices.CurrencyPair = pair
+		prices.setTimestamp(k.location())
+	}
+
+	return tickers, nil
+}
+
 // OrderbookResp contains all the bid and ask orders on the books.
 type OrderbookResp struct {
 	Timestamp int64      `json:"timestamp"`
@@ -60,62 +116,91 @@ type OrderbookResp struct {
 
 // Orderbook is for returning the orderbook to the user in a form without strings.
 type Orderbook struct {
-	Timestamp int64
-	Asks      []OrderbookOrder
-	Bids      []OrderbookOrder
+	CurrencyPair string           `json:"currency_pair"`
+	Timestamp    int64            `json:"timestamp"`
+	Asks         []OrderbookOrder `json:"asks"`
+	Bids         []OrderbookOrder `json:"bids"`
 }
 
-// OrderbookOrder is what is in the slice of orders from the orderbook.
+// OrderbookOrder is what is in the slice of orders from the orderbook. Orders is the
+// number of orders resting at the price, the third element korbit sends for a level.
 type OrderbookOrder struct {
-	Price int64
-	Qty   float64
+	Price  int64   `json:"price"`
+	Qty    float64 `json:"qty"`
+	Orders int64   `json:"orders,omitempty"`
 }
 
 // Transform is what turned the korbit response into something usable, the korbit api had
 // them as lists which each index had a specific meaning, these are better expressed as
-// key-value pairs.
+// key-value pairs. The levels are checked to be well formed, bids descending, asks
+// ascending and the book not crossed.
 func (o *OrderbookResp) Transform() (*Orderbook, error) {
 	ret := Orderbook{Timestamp: o.Timestamp}
 
-	for _, v := range o.Bids {
-		price, err := strconv.ParseInt(v[0], 10, 64)
-		if err != nil {
-			return nil, errors.New("error changing bid price to int64")
-		}
-
-		qty, err := strconv.ParseFloat(v[1], 10)
-		if err != nil {
-			return nil, errors.New("error changing bid qty to float64")
-		}
+	var err error
+	ret.Bids, err = parseLevels(Bid, o.Bids)
+	if err != nil {
+		return nil, err
+	}
 
-		bid := OrderbookOrder{
-			Price: price,
-			Qty:   qty,
-		}
+	ret.Asks, err = parseLevels(Ask, o.Asks)
+	if err != nil {
+		return nil, err
+	}
 
-		ret.Bids = append(ret.Bids, bid)
+	bid, hasBid := ret.BestBid()
+	ask, hasAsk := ret.BestAsk()
+	if hasBid && hasAsk && bid.Price >= ask.Price {
+		return nil, errors.Errorf("orderbook crossed: best bid %d best ask %d", bid.Price, ask.Price)
 	}
 
-	for _, v := range o.Asks {
+	return &ret, nil
+}
+
+// parseLevels turns the string levels of one side of the book into orders, checking that
+// the prices move away from the top of the book.
+func parseLevels(side string, levels [][]string) ([]OrderbookOrder, error) {
+	ret := make([]OrderbookOrder, 0, len(levels))
+
+	for i, v := range levels {
+		if len(v) < 2 {
+			return nil, errors.Errorf("%s level %d has %d fields, want at least 2", side, i, len(v))
+		}
+
 		price, err := strconv.ParseInt(v[0], 10, 64)
 		if err != nil {
-			return nil, errors.New("error changing ask price to int64")
+			return nil, errors.Wrapf(err, "%s level %d price", side, i)
 		}
 
-		qty, err := strconv.ParseFloat(v[1], 10)
+		qty, err := strconv.ParseFloat(v[1], 64)
 		if err != nil {
-			return nil, errors.New("error changing ask qty to float64")
+			return nil, errors.Wrapf(err, "%s level %d qty", side, i)
 		}
 
-		ask := OrderbookOrder{
+		order := OrderbookOrder{
 			Price: price,
 			Qty:   qty,
 		}
 
-		ret.Asks = append(ret.Asks, ask)
+		if len(v) > 2 {
+			order.Orders, err = strconv.ParseInt(v[2], 10, 64)
+			if err != nil {
+				return nil, errors.Wrapf(err, "%s level %d orders", side, i)
+			}
+		}
+
+		if i > 0 {
+			prev := ret[i-1].Price
+			if side == Bid && price >= prev || side == Ask && price <= prev {
+				return nil, errors.Errorf("%s level %d price %d out of order after %d",
+					side, i, price, prev)
+			}
+		}
+
+		ret = append(ret, order)
 	}
 
-	return &ret, nil
+	return ret, nil
 }
 
 // GetOrderbook fetches the orderbook for the given coin.
@@ -147,6 +232,72 @@ func (k *API) GetOrderbook(coin string) (*Orderbook, error) {
 	if err != nil {
 		return nil, errors.Wrap(err, "transforming OBresp")
 	}
+	retResp.CurrencyPair = coin
 
 	return retResp, nil
 }
+
+// Minute and the other windows here are the periods that public trades can be fetched
+// for.
+var (
+	Minute = "minute"
+	Hour   = "hour"
+	Day    = "day"
+)
+
+// Trade is a single trade from the public trade tape. Side is the side of the taker.
+type Trade struct {
+	CurrencyPair string  `json:"currency_pair"`
+	Timestamp    int64   `json:"timestamp"`
+	TID          int64   `json:"tid,string"`
+	Price        int64   `json:"price,string"`
+	Amount       float64 `json:"amount,string"`
+	Side         string  `json:"type"`
+}
+
+// Time gives the millisecond timestamp of the trade as a time.
+func (t *Trade) Time() time.Time {
+	return millisToTime(t.Timestamp)
+}
+
+// Key identifies the trade by its time, price and amount. The websocket does not send
+// the tid so this is what tells trades apart whichever way they were fetched.
+func (t *Trade) Key() string {
+	return fmt.Sprintf("%d:%d:%s", t.Timestamp, t.Price, strconv.FormatFloat(t.Amount, 'f', -1, 64))
+}
+
+// GetTrades fetches the public trades of the pair that happened in the last minute, hour
+// or day, newest first.
+func (k *API) GetTrades(coin, window string) ([]Trade, error) {
+	if window != Minute && window != Hour && window != Day {
+		return nil, errors.Errorf("window must be one of %s, %s or %s", Minute, Hour, Day)
+	}
+
+	url := fmt.Sprintf("%s?currency_pair=%s&time=%s", PublicTrades, coin, window)
+	req, err := k.NewRequest(url, "GET", nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "korbit get trades")
+	}
+
+	resp, err := k.Client.Do(req)
+	if err != nil {
+		return nil, errors.Wrapf(err, "korbit trades fetch for %s", coin)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("status: %d Header: %v", resp.StatusCode, resp.Header)
+	}
+
+	var trades []Trade
+	err = json.NewDecoder(resp.Body).Decode(&trades)
+	if err != nil {
+		return nil, errors.Wrapf(err, "korbit trades json decode for %s", coin)
+	}
+
+	for i := range trades {
+		trades[i].CurrencyPair = coin
+	}
+
+	return trades, nil
+}
@@ -1,7 +1,11 @@
 package korbit
 
 import (
+	"fmt"
+	"net/http"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestGetPrices(t *testing.T) {
@@ -21,3 +25,127 @@ func TestGetOrderbook(t *testing.T) {
 		}
 	}
 }
+
+func TestGetTrades(t *testing.T) {
+	k := newTestAPI(t, &PublicTrades, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("time") != Hour {
+			t.Errorf("unexpected window: %s", r.URL.RawQuery)
+		}
+		fmt.Fprint(w, `[
+			{"timestamp": 1389678052000, "tid": "22546", "price": "569000", "amount": "0.01000000", "type": "sell"},
+			{"timestamp": 1389678017000, "tid": "22545", "price": "580000", "amount": "0.01000000", "type": "buy"}
+		]`)
+	})
+
+	trades, err := k.GetTrades(BTCKRW, Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(trades) != 2 {
+		t.Fatalf("got %d trades, want 2", len(trades))
+	}
+	want := Trade{CurrencyPair: BTCKRW, Timestamp: 1389678052000, TID: 22546, Price: 569000, Amount: 0.01, Side: Sell}
+	if trades[0] != want {
+		t.Errorf("got %+v, want %+v", trades[0], want)
+	}
+	if trades[0].Time().Unix() != 1389678052 {
+		t.Errorf("unexpected time: %v", trades[0].Time())
+	}
+
+	_, err = k.GetTrades(BTCKRW, "week")
+	if err == nil {
+		t.Error("expected error for unknown window")
+	}
+}
+
+func TestGetAllTickers(t *testing.T) {
+	k := newTestAPI(t, &AllTickers, func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			t.Errorf("request was not made with NewRequest: %v", r.Header)
+		}
+		fmt.Fprint(w, `{
+			"btc_krw": {"timestamp": 1558590089274, "last": "9198500", "open": "9500000",
+				"bid": "9192500", "ask": "9198000", "low": "9171500", "high": "9599000",
+				"volume": "1539.18571988", "change": "-301500", "changePercent": "-3.17"},
+			"eth_krw": {"timestamp": 1558590089275, "last": "300000", "open": "290000",
+				"bid": "299950", "ask": "300000", "low": "280000", "high": "310000",
+				"volume": "20000.5", "change": "10000", "changePercent": "3.44"}
+		}`)
+	})
+
+	tickers, err := k.GetAllTickers()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	btc := tickers[BTCKRW]
+	if btc == nil || len(tickers) != 2 {
+		t.Fatalf("unexpected tickers: %v", tickers)
+	}
+	if btc.CurrencyPair != BTCKRW || btc.Open != 9500000 || btc.Change != -301500 ||
+		btc.ChangePercent != -3.17 || btc.Volume != 1539.18571988 {
+		t.Errorf("unexpected btc ticker: %+v", btc)
+	}
+	if btc.Timestamp.UnixNano() != 1558590089274*int64(time.Millisecond) {
+		t.Errorf("timestamp not populated: %v", btc.Timestamp)
+	}
+}
+
+func TestPricesTimestamp(t *testing.T) {
+	now := time.Now()
+	k := newTestAPI(t, &DetailedTicker, func(w http.ResponseWriter, r *http.Request) {
+		ms := now.Add(-time.Minute).UnixNano() / int64(time.Millisecond)
+		fmt.Fprintf(w, `{"timestamp": %d, "last": "9198500", "bid": "9192500", "ask": "9198000",
+			"low": "9171500", "high": "9599000", "volume": "1539.18571988"}`, ms)
+	})
+	k.Location = KST
+
+	prices, err := k.GetPrices(BTCKRW)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if prices.Timestamp.Location() != KST {
+		t.Errorf("timestamp in %v, want KST", prices.Timestamp.Location())
+	}
+	if !prices.IsStale(30*time.Second) || prices.IsStale(2*time.Minute) {
+		t.Errorf("unexpected staleness for a quote that is %s old", prices.Age())
+	}
+}
+
+func TestTransform(t *testing.T) {
+	resp := OrderbookResp{
+		Timestamp: 1500000000000,
+		Bids:      [][]string{{"9990", "1.5", "2"}, {"9980", "0.25", "1"}},
+		Asks:      [][]string{{"10010", "3", "4"}, {"10020", "1", "1"}},
+	}
+
+	book, err := resp.Transform()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if book.Bids[0] != (OrderbookOrder{Price: 9990, Qty: 1.5, Orders: 2}) || len(book.Asks) != 2 {
+		t.Errorf("unexpected book: %+v", book)
+	}
+
+	bad := map[string]OrderbookResp{
+		"short level": {Bids: [][]string{{"9990"}}},
+		"bad qty":     {Asks: [][]string{{"10010", "x"}}},
+		"bids order":  {Bids: [][]string{{"9980", "1"}, {"9990", "1"}}},
+		"asks order":  {Asks: [][]string{{"10010", "1"}, {"10010", "1"}}},
+		"crossed":     {Bids: [][]string{{"10010", "1"}}, Asks: [][]string{{"10000", "1"}}},
+	}
+	for name, resp := range bad {
+		_, err := resp.Transform()
+		if err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+
+	badQty := bad["bad qty"]
+	_, err = badQty.Transform()
+	if err == nil || !strings.HasPrefix(err.Error(), "ask level 0 qty") {
+		t.Errorf("error does not identify the level: %v", err)
+	}
+}
@@ -0,0 +1,590 @@
+package korbit
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/pkg/errors"
+)
+
+// Gzip and Zstd are the compressions that a Recorder can write.
+const (
+	Gzip = "gzip"
+	Zstd = "zstd"
+)
+
+// recordingDay is the layout of the day directories of a recording.
+const recordingDay = "2006-01-02"
+
+// tradeKeyWindow is how far back from the newest recorded trade the keys of trades are
+// kept to tell repeats apart. Older trades are taken to be recorded already.
+const tradeKeyWindow = time.Minute
+
+// recordedTrades is what a Recorder knows about the trades of a pair it recorded. Trades
+// are told apart by tid when both trades have one. The websocket does not send tids so
+// trades without one are told apart by their keys.
+type recordedTrades struct {
+	newest time.Time
+	maxTID int64
+	keys   map[string]recordedKey
+}
+
+// recordedKey is when the trade with a key happened and its tid, 0 if it had none.
+type recordedKey struct {
+	at  time.Time
+	tid int64
+}
+
+func newRecordedTrades(trades ...Trade) *recordedTrades {
+	r := &recordedTrades{keys: map[string]recordedKey{}}
+	for _, t := range trades {
+		r.add(t)
+	}
+	return r
+}
+
+// seen reports whether the trade was recorded already. A trade with a tid is only taken
+// for a recorded one without a tid, the same trade from the websocket, by its key.
+func (r *recordedTrades) seen(t Trade) bool {
+	k, ok := r.keys[t.Key()]
+	if t.TID != 0 {
+		return t.TID <= r.maxTID || ok && k.tid == 0
+	}
+	if ok {
+		return true
+	}
+	return t.Time().Before(r.newest.Add(-tradeKeyWindow))
+}
+
+// add remembers the trade and forgets the keys that fell out of the window.
+func (r *recordedTrades) add(t Trade) {
+	at := t.Time()
+	r.keys[t.Key()] = recordedKey{at: at, tid: t.TID}
+	if t.TID > r.maxTID {
+		r.maxTID = t.TID
+	}
+	if !at.After(r.newest) {
+		return
+	}
+
+	r.newest = at
+	for key, k := range r.keys {
+		if k.at.Before(at.Add(-tradeKeyWindow)) {
+			delete(r.keys, key)
+		}
+	}
+}
+
+// recordFile is a file that a Recorder is writing to.
+type recordFile struct {
+	file   *os.File
+	comp   interface{ Flush() error }
+	closer io.Closer
+	events *EventWriter
+	day    string
+	opened time.Time // time of the first event in the file
+}
+
+// close flushes the compressor and closes the file.
+func (f *recordFile) close() error {
+	err := f.closer.Close()
+	if err != nil {
+		f.file.Close()
+		return errors.Wrapf(err, "close compressor of %s", f.file.Name())
+	}
+	return errors.Wrapf(f.file.Close(), "close %s", f.file.Name())
+}
+
+// Recorder writes the events of a MarketDataSource to compressed NDJSON files that can be
+// read back with OpenRecording. Files are kept at DIR/PAIR/DAY/ and a new one is started
+// every RotateEvery and at the start of every day, going by the time of the events.
+//
+// When a recorder starts on a directory that already has trades of a pair, the trades
+// missed while it was not running are fetched with GetTrades and written before the live
+// ones, so the trades have no gaps as long as it was down for less than a day. Tickers
+// and orderbooks can not be recovered that way.
+type Recorder struct {
+	Dir         string
+	Compression string
+	RotateEvery time.Duration
+	FlushEvery  time.Duration  // how often the files are flushed to disk
+	Location    *time.Location // the location the days are in
+
+	source MarketDataSource
+	trades func(pair, window string) ([]Trade, error)
+	pairs  []string
+	errors chan error
+	stop   chan struct{}
+	done   chan struct{}
+	once   sync.Once
+	err    error // the error of closing the files, set before done is closed
+
+	// only used by the recording goroutine after Start.
+	files    map[string]*recordFile
+	recorded map[string]*recordedTrades // pair -> trades recorded
+}
+
+// NewRecorder returns a recorder of the pairs from the source into dir, with gzip files
+// rotated every hour and flushed every second. Nothing is recorded until Start.
+func NewRecorder(k *API, source MarketDataSource, dir string, pairs ...string) *Recorder {
+	return &Recorder{
+		Dir:         dir,
+		Compression: Gzip,
+		RotateEvery: time.Hour,
+		FlushEvery:  time.Second,
+		Location:    time.UTC,
+		source:      source,
+		trades:      k.GetTrades,
+		pairs:       pairs,
+		errors:      make(chan error, 1),
+		stop:        make(chan struct{}),
+		done:        make(chan struct{}),
+		files:       map[string]*recordFile{},
+		recorded:    map[string]*recordedTrades{},
+	}
+}
+
+// Errors gives the channel that recording errors are sent on, like the errors of the
+// source and gaps that could not be backfilled. Errors are dropped while the previous
+// one has not been received.
+func (r *Recorder) Errors() <-chan error {
+	return r.errors
+}
+
+// Start finds the last recorded trades of every pair, subscribes the source to the pairs
+// and records in the background until Close.
+func (r *Recorder) Start() error {
+	if r.Compression != Gzip && r.Compression != Zstd {
+		return errors.Errorf("unrecognized compression: %s", r.Compression)
+	}
+
+	for _, pair := range r.pairs {
+		last, err := lastRecordedTrades(filepath.Join(r.Dir, pair))
+		if err != nil {
+			return errors.Wrapf(err, "last recorded trades of %s", pair)
+		}
+		if len(last) > 0 {
+			r.recorded[pair] = newRecordedTrades(last...)
+		}
+	}
+
+	err := r.source.Subscribe(r.pairs...)
+	if err != nil {
+		return errors.Wrap(err, "subscribe recorder")
+	}
+	r.source.Start()
+
+	go r.run()
+	return nil
+}
+
+// Close stops recording, closes the source and closes the files.
+func (r *Recorder) Close() error {
+	r.once.Do(func() { close(r.stop) })
+	<-r.done
+	return r.err
+}
+
+func (r *Recorder) run() {
+	defer close(r.done)
+
+	// the source is already running so the live trades overlap the backfill.
+	for _, pair := range r.pairs {
+		r.backfill(pair)
+	}
+
+	flush := time.NewTicker(r.FlushEvery)
+	defer flush.Stop()
+
+	events, sourceErrors := r.source.Events(), r.source.Errors()
+loop:
+	for {
+		select {
+		case <-r.stop:
+			break loop
+		case e, ok := <-events:
+			if !ok {
+				break loop
+			}
+			r.write(e)
+		case err := <-sourceErrors:
+			r.report(err)
+		case <-flush.C:
+			for _, f := range r.files {
+				if err := f.comp.Flush(); err != nil {
+					r.report(errors.Wrapf(err, "flush %s", f.file.Name()))
+				}
+			}
+		}
+	}
+
+	r.source.Close()
+	for pair, f := range r.files {
+		if err := f.close(); err != nil && r.err == nil {
+			r.err = err
+		}
+		delete(r.files, pair)
+	}
+}
+
+// backfill writes the trades that were made since the newest recorded trade of the pair.
+func (r *Recorder) backfill(pair string) {
+	recorded, ok := r.recorded[pair]
+	if !ok {
+		return
+	}
+	newest := recorded.newest
+
+	window := Day
+	switch since := time.Since(newest); {
+	case since < time.Minute:
+		window = Minute
+	case since < time.Hour:
+		window = Hour
+	}
+
+	trades, err := r.trades(pair, window)
+	if err != nil {
+		r.report(errors.Wrapf(err, "backfill trades of %s", pair))
+		return
+	}
+
+	// trades come newest first.
+	if n := len(trades); n > 0 && trades[n-1].Time().After(newest) {
+		r.report(errors.Errorf("%s trades are missing between %s and %s", pair, newest,
+			trades[n-1].Time()))
+	}
+	for i := len(trades) - 1; i >= 0; i-- {
+		t := trades[i]
+		t.CurrencyPair = pair
+		r.write(MarketEvent{Type: TradeEvent, CurrencyPair: pair, Trade: &t})
+	}
+}
+
+// write writes the event to the file of its pair, skipping trades that were recorded
+// already.
+func (r *Recorder) write(e MarketEvent) {
+	t := e.Time()
+	if t.IsZero() {
+		return
+	}
+
+	if e.Type == TradeEvent {
+		recorded, ok := r.recorded[e.CurrencyPair]
+		if !ok {
+			recorded = newRecordedTrades()
+			r.recorded[e.CurrencyPair] = recorded
+		}
+		if recorded.seen(*e.Trade) {
+			return
+		}
+		recorded.add(*e.Trade)
+	}
+
+	f, err := r.file(e.CurrencyPair, t)
+	if err != nil {
+		r.report(err)
+		return
+	}
+
+	err = f.events.Write(e)
+	if err != nil {
+		r.report(errors.Wrapf(err, "record to %s", f.file.Name()))
+	}
+}
+
+// file gives the file that an event of the pair at t goes to, rotating when it is time.
+func (r *Recorder) file(pair string, t time.Time) (*recordFile, error) {
+	t = t.In(r.Location)
+	day := t.Format(recordingDay)
+
+	f := r.files[pair]
+	if f != nil && f.day == day && t.Sub(f.opened) < r.RotateEvery {
+		return f, nil
+	}
+
+	if f != nil {
+		delete(r.files, pair)
+		err := f.close()
+		if err != nil {
+			r.report(err)
+		}
+	}
+
+	dir := filepath.Join(r.Dir, pair, day)
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return nil, errors.Wrapf(err, "make %s", dir)
+	}
+
+	ext := "gz"
+	if r.Compression == Zstd {
+		ext = "zst"
+	}
+
+	// a restart in the same second must not write over the file from before it.
+	var file *os.File
+	for seq := 0; ; seq++ {
+		name := fmt.Sprintf("%s-%s-%02d.ndjson.%s", pair, t.Format("20060102T150405"), seq, ext)
+		file, err = os.OpenFile(filepath.Join(dir, name), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+		if os.IsExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "create recording in %s", dir)
+		}
+		break
+	}
+
+	f = &recordFile{file: file, day: day, opened: t}
+	if r.Compression == Zstd {
+		zw, err := zstd.NewWriter(file)
+		if err != nil {
+			file.Close()
+			return nil, errors.Wrap(err, "zstd writer")
+		}
+		f.comp, f.closer = zw, zw
+		f.events = NewEventWriter(zw)
+	} else {
+		gw := gzip.NewWriter(file)
+		f.comp, f.closer = gw, gw
+		f.events = NewEventWriter(gw)
+	}
+
+	r.files[pair] = f
+	return f, nil
+}
+
+func (r *Recorder) report(err error) {
+	select {
+	case r.errors <- err:
+	default:
+	}
+}
+
+// recordingFiles lists the recorded files of a pair oldest first, leaving out the days
+// that are clearly outside of from and to when those are set.
+func recordingFiles(pairDir string, from, to time.Time) ([]string, error) {
+	days, err := os.ReadDir(pairDir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
+	for _, d := range days {
+		day, err := time.Parse(recordingDay, d.Name())
+		if !d.IsDir() || err != nil {
+			continue
+		}
+		// the day is in the recorder's location, which can be up to a day off of UTC.
+		if !from.IsZero() && day.Add(48*time.Hour).Before(from) ||
+			!to.IsZero() && day.Add(-24*time.Hour).After(to) {
+			continue
+		}
+
+		entries, err := os.ReadDir(filepath.Join(pairDir, d.Name()))
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range entries {
+			if !e.IsDir() && strings.Contains(e.Name(), ".ndjson") {
+				files = append(files, filepath.Join(pairDir, d.Name(), e.Name()))
+			}
+		}
+	}
+
+	sort.Strings(files)
+	return files, nil
+}
+
+// lastRecordedTrades gives the trades of the newest file of the pair that has any, those
+// are enough to tell the live trades that were recorded already apart from new ones.
+func lastRecordedTrades(pairDir string) ([]Trade, error) {
+	files, err := recordingFiles(pairDir, time.Time{}, time.Time{})
+	if err != nil {
+		return nil, err
+	}
+
+	for i := len(files) - 1; i >= 0; i-- {
+		src := &fileEvents{paths: files[i : i+1]}
+		var trades []Trade
+		for {
+			e, err := src.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				src.close()
+				return nil, err
+			}
+			if e.Type == TradeEvent {
+				trades = append(trades, *e.Trade)
+			}
+		}
+		src.close()
+
+		if len(trades) > 0 {
+			return trades, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// openEventFile opens a recorded file, decompressing it by its extension.
+func openEventFile(path string) (io.Reader, io.Closer, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	switch {
+	case strings.HasSuffix(path, ".gz"):
+		gr, err := gzip.NewReader(file)
+		if err != nil {
+			file.Close()
+			return nil, nil, errors.Wrapf(err, "gzip %s", path)
+		}
+		return gr, file, nil
+	case strings.HasSuffix(path, ".zst"):
+		zr, err := zstd.NewReader(file)
+		if err != nil {
+			file.Close()
+			return nil, nil, errors.Wrapf(err, "zstd %s", path)
+		}
+		return zr, closerFunc(func() error {
+			zr.Close()
+			return file.Close()
+		}), nil
+	}
+	return file, file, nil
+}
+
+// closerFunc lets a function be used as an io.Closer.
+type closerFunc func() error
+
+func (f closerFunc) Close() error {
+	return f()
+}
+
+// fileEvents reads the events of files one after the other. A file that was cut short,
+// like the last file of a recorder that crashed, ends at the last whole event.
+type fileEvents struct {
+	paths  []string
+	reader *EventReader
+	closer io.Closer
+}
+
+// Next gives the next event of the files.
+func (f *fileEvents) Next() (MarketEvent, error) {
+	for {
+		if f.reader == nil {
+			if len(f.paths) == 0 {
+				return MarketEvent{}, io.EOF
+			}
+			r, closer, err := openEventFile(f.paths[0])
+			if err != nil {
+				return MarketEvent{}, errors.Wrap(err, "open recording")
+			}
+			f.paths = f.paths[1:]
+			f.reader, f.closer = NewEventReader(r), closer
+		}
+
+		e, err := f.reader.Next()
+		if err == nil {
+			return e, nil
+		}
+
+		// an error followed by the end of the file is a cut off tail.
+		if err != io.EOF && !truncated(err) {
+			if _, next := f.reader.Next(); next != io.EOF && !truncated(next) {
+				return MarketEvent{}, err
+			}
+		}
+		f.close()
+	}
+}
+
+// truncated reports whether the error is from a compressed file that was cut short.
+func truncated(err error) bool {
+	return errors.Cause(err) == io.ErrUnexpectedEOF
+}
+
+func (f *fileEvents) close() error {
+	f.reader = nil
+	if f.closer == nil {
+		return nil
+	}
+	err := f.closer.Close()
+	f.closer = nil
+	return err
+}
+
+// Recording reads back the events that a Recorder wrote, as the same MarketEvents.
+type Recording struct {
+	from, to time.Time
+	files    []*fileEvents
+	merged   EventSource
+}
+
+// OpenRecording opens the recording of the pairs in dir and gives their events merged in
+// time order. Only the events from from up to to are given, either can be zero to leave
+// that end open.
+func OpenRecording(dir string, from, to time.Time, pairs ...string) (*Recording, error) {
+	rec := Recording{from: from, to: to}
+
+	var sources []EventSource
+	for _, pair := range pairs {
+		paths, err := recordingFiles(filepath.Join(dir, pair), from, to)
+		if err != nil {
+			return nil, errors.Wrapf(err, "list recording of %s", pair)
+		}
+		f := &fileEvents{paths: paths}
+		rec.files = append(rec.files, f)
+		sources = append(sources, f)
+	}
+
+	rec.merged = MergeEvents(sources...)
+	return &rec, nil
+}
+
+// Next gives the next event of the recording, io.EOF when there are no more.
+func (r *Recording) Next() (MarketEvent, error) {
+	for {
+		e, err := r.merged.Next()
+		if err != nil {
+			return e, err
+		}
+
+		t := e.Time()
+		if !r.from.IsZero() && t.Before(r.from) {
+			continue
+		}
+		if !r.to.IsZero() && t.After(r.to) {
+			return MarketEvent{}, io.EOF
+		}
+		return e, nil
+	}
+}
+
+// Close closes the open files.
+func (r *Recording) Close() error {
+	var first error
+	for _, f := range r.files {
+		if err := f.close(); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
@@ -0,0 +1,225 @@
+package korbit
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// fakeSource is a MarketDataSource that delivers the events it is given.
+type fakeSource struct {
+	events chan MarketEvent
+	errors chan error
+}
+
+func newFakeSource(events ...MarketEvent) *fakeSource {
+	s := &fakeSource{events: make(chan MarketEvent, len(events)), errors: make(chan error)}
+	for _, e := range events {
+		s.events <- e
+	}
+	close(s.events)
+	return s
+}
+
+func (s *fakeSource) Subscribe(pairs ...string) error { return nil }
+func (s *fakeSource) Start()                          {}
+func (s *fakeSource) Events() <-chan MarketEvent      { return s.events }
+func (s *fakeSource) Errors() <-chan error            { return s.errors }
+func (s *fakeSource) Close() error                    { return nil }
+
+// record runs a recorder over the events until they are all written.
+func record(t *testing.T, rec *Recorder) {
+	err := rec.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-rec.done
+	if err := rec.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func readRecording(t *testing.T, dir string, pairs ...string) []MarketEvent {
+	r, err := OpenRecording(dir, time.Time{}, time.Time{}, pairs...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	var events []MarketEvent
+	for {
+		e, err := r.Next()
+		if err == io.EOF {
+			return events
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		events = append(events, e)
+	}
+}
+
+func TestRecorderRoundTrip(t *testing.T) {
+	day := int64(24 * 60 * 60 * 1000)
+	start := int64(1500000000000)
+
+	for _, compression := range []string{Gzip, Zstd} {
+		dir := t.TempDir()
+		events := []MarketEvent{
+			{Type: TickerEvent, CurrencyPair: BTCKRW,
+				Ticker: &Prices{CurrencyPair: BTCKRW, TimestampMillis: start, Last: 3000000}},
+			bookEvent(start+1000, 3000000, 3000500),
+			tradeEvent(start+2000, 1, 3000500, 0.1),
+			tradeEvent(start+day, 2, 3000000, 0.2),
+		}
+
+		rec := NewRecorder(nil, newFakeSource(events...), dir, BTCKRW)
+		rec.Compression = compression
+		record(t, rec)
+
+		days, _ := os.ReadDir(filepath.Join(dir, BTCKRW))
+		if len(days) != 2 {
+			t.Errorf("%s: expected a directory per day, got %d", compression, len(days))
+		}
+
+		got := readRecording(t, dir, BTCKRW)
+		if len(got) != len(events) {
+			t.Fatalf("%s: read %d events", compression, len(got))
+		}
+		for i := range events {
+			if got[i].Type != events[i].Type || !got[i].Time().Equal(events[i].Time()) {
+				t.Errorf("%s: event %d is %+v", compression, i, got[i])
+			}
+		}
+		if got[0].Ticker.Last != 3000000 || got[1].Orderbook.Bids[0].Price != 3000000 ||
+			got[3].Trade.TID != 2 {
+			t.Errorf("%s: unexpected payloads: %+v", compression, got)
+		}
+	}
+}
+
+func TestRecorderRestartBackfills(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now().UnixNano() / int64(time.Millisecond)
+
+	first := NewRecorder(nil, newFakeSource(tradeEvent(now-3000, 1, 100, 1), tradeEvent(now-2000, 2, 100, 1)),
+		dir, BTCKRW)
+	record(t, first)
+
+	second := NewRecorder(nil, newFakeSource(tradeEvent(now, 4, 100, 1), tradeEvent(now+1000, 5, 100, 1)),
+		dir, BTCKRW)
+	second.trades = func(pair, window string) ([]Trade, error) {
+		if window != Minute {
+			t.Errorf("unexpected backfill window: %s", window)
+		}
+		return []Trade{
+			*tradeEvent(now, 4, 100, 1).Trade,
+			*tradeEvent(now-1000, 3, 100, 1).Trade,
+			*tradeEvent(now-2000, 2, 100, 1).Trade,
+		}, nil
+	}
+	record(t, second)
+
+	got := readRecording(t, dir, BTCKRW)
+	if len(got) != 5 {
+		t.Fatalf("expected 5 trades, got %d", len(got))
+	}
+	for i, e := range got {
+		if e.Trade.TID != int64(i+1) {
+			t.Errorf("trade %d has tid %d", i, e.Trade.TID)
+		}
+	}
+}
+
+func TestRecordingTruncatedFile(t *testing.T) {
+	dir := t.TempDir()
+	var events []MarketEvent
+	for i := int64(1); i <= 200; i++ {
+		events = append(events, tradeEvent(1500000000000+i*1000, i, 100, 1))
+	}
+	record(t, NewRecorder(nil, newFakeSource(events...), dir, BTCKRW))
+
+	files, _ := recordingFiles(filepath.Join(dir, BTCKRW), time.Time{}, time.Time{})
+	if len(files) != 1 {
+		t.Fatalf("unexpected files: %v", files)
+	}
+	info, _ := os.Stat(files[0])
+	if err := os.Truncate(files[0], info.Size()-20); err != nil {
+		t.Fatal(err)
+	}
+
+	got := readRecording(t, dir, BTCKRW)
+	if len(got) == 0 || len(got) > 200 {
+		t.Errorf("unexpected events from a cut off file: %d", len(got))
+	}
+}
+
+func TestRecorderWebSocketTrades(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now().UnixNano() / int64(time.Millisecond)
+
+	// trades from the websocket have no tid, two of them happen in the same millisecond
+	// and the last one is delivered twice.
+	events := []MarketEvent{
+		tradeEvent(now-3000, 0, 100, 1),
+		tradeEvent(now-2000, 0, 101, 1),
+		tradeEvent(now-2000, 0, 102, 1),
+		tradeEvent(now-1000, 0, 103, 0.5),
+		tradeEvent(now-1000, 0, 103, 0.5),
+	}
+	record(t, NewRecorder(nil, newFakeSource(events...), dir, BTCKRW))
+
+	got := readRecording(t, dir, BTCKRW)
+	if len(got) != 4 {
+		t.Fatalf("expected 4 trades, got %d", len(got))
+	}
+	for i, e := range got {
+		if e.Trade.Price != int64(100+i) {
+			t.Errorf("trade %d has price %d", i, e.Trade.Price)
+		}
+	}
+
+	// after a restart the backfill has tids for the same trades and one that was missed.
+	second := NewRecorder(nil, newFakeSource(tradeEvent(now+1000, 0, 105, 1)), dir, BTCKRW)
+	second.trades = func(pair, window string) ([]Trade, error) {
+		return []Trade{
+			*tradeEvent(now, 11, 104, 1).Trade,
+			*tradeEvent(now-1000, 10, 103, 0.5).Trade,
+			*tradeEvent(now-2000, 9, 102, 1).Trade,
+		}, nil
+	}
+	record(t, second)
+
+	got = readRecording(t, dir, BTCKRW)
+	if len(got) != 6 {
+		t.Fatalf("expected 6 trades after the restart, got %d", len(got))
+	}
+	if got[4].Trade.Price != 104 || got[5].Trade.Price != 105 {
+		t.Errorf("unexpected trades after the restart: %+v %+v", got[4].Trade, got[5].Trade)
+	}
+}
+
+func TestRecorderSameKeyTrades(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now().UnixNano() / int64(time.Millisecond)
+
+	// two trades with tids fill the same amount at the same price in the same
+	// millisecond, the websocket then delivers the second one without its tid.
+	events := []MarketEvent{
+		tradeEvent(now, 1, 100, 1),
+		tradeEvent(now, 2, 100, 1),
+		tradeEvent(now, 2, 100, 1),
+		tradeEvent(now, 0, 100, 1),
+	}
+	record(t, NewRecorder(nil, newFakeSource(events...), dir, BTCKRW))
+
+	got := readRecording(t, dir, BTCKRW)
+	if len(got) != 2 {
+		t.Fatalf("expected 2 trades, got %d", len(got))
+	}
+	if got[0].Trade.TID != 1 || got[1].Trade.TID != 2 {
+		t.Errorf("unexpected tids %d and %d", got[0].Trade.TID, got[1].Trade.TID)
+	}
+}
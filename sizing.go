@@ -0,0 +1,119 @@
+package korbit
+
+import (
+	"math"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Precision is how finely the price and the coin amount of an order can be given for a
+// pair.
+type Precision struct {
+	PriceTick   int64 // smallest price step in KRW
+	QtyDecimals int   // decimals allowed in the coin amount
+}
+
+// Precisions are the order precisions of the pairs traded on korbit.
+var Precisions = map[string]Precision{
+	BTCKRW: {PriceTick: 500, QtyDecimals: 8},
+	ETHKRW: {PriceTick: 50, QtyDecimals: 8},
+	ETCKRW: {PriceTick: 10, QtyDecimals: 8},
+	XRPKRW: {PriceTick: 1, QtyDecimals: 6},
+}
+
+// roundingSlack keeps values like 0.29999999999 from being floored a whole step down.
+const roundingSlack = 1e-9
+
+// PrecisionFor gives the precision of the pair.
+func PrecisionFor(pair string) (Precision, error) {
+	p, ok := Precisions[pair]
+	if !ok {
+		return Precision{}, errors.Errorf("no precision for %s", pair)
+	}
+	return p, nil
+}
+
+// FloorQty rounds the quantity down to the allowed decimals.
+func (p Precision) FloorQty(qty float64) float64 {
+	scale := math.Pow10(p.QtyDecimals)
+	return math.Floor(qty*scale+roundingSlack) / scale
+}
+
+// FloorPrice rounds the price down to a multiple of the tick.
+func (p Precision) FloorPrice(price float64) int64 {
+	return int64(math.Floor(price/float64(p.PriceTick)+roundingSlack)) * p.PriceTick
+}
+
+// CeilPrice rounds the price up to a multiple of the tick.
+func (p Precision) CeilPrice(price float64) int64 {
+	return int64(math.Ceil(price/float64(p.PriceTick)-roundingSlack)) * p.PriceTick
+}
+
+// PairCurrencies splits a pair like btc_krw into the coin and the fiat currency.
+func PairCurrencies(pair string) (coin, fiat string) {
+	parts := strings.SplitN(pair, "_", 2)
+	if len(parts) != 2 {
+		return pair, ""
+	}
+	return parts[0], parts[1]
+}
+
+// MaxBuyQty gives the largest quantity that can be bought at price without the order and
+// its fee going over the KRW budget.
+func MaxBuyQty(pair string, budget, price int64, feeRate float64) (float64, error) {
+	p, err := PrecisionFor(pair)
+	if err != nil {
+		return 0, err
+	}
+	if price <= 0 {
+		return 0, errors.New("price must be positive")
+	}
+
+	qty := float64(budget) / (float64(price) * (1 + feeRate))
+	return p.FloorQty(qty), nil
+}
+
+// MaxBuyQtyFromBalances is MaxBuyQty with the available KRW balance as the budget.
+func MaxBuyQtyFromBalances(b Balances, pair string, price int64, feeRate float64) (float64, error) {
+	_, fiat := PairCurrencies(pair)
+	return MaxBuyQty(pair, int64(b[fiat].Available), price, feeRate)
+}
+
+// MaxSellQty gives the available coin balance of the pair rounded to its precision.
+func MaxSellQty(b Balances, pair string) (float64, error) {
+	p, err := PrecisionFor(pair)
+	if err != nil {
+		return 0, err
+	}
+
+	coin, _ := PairCurrencies(pair)
+	return p.FloorQty(b[coin].Available), nil
+}
+
+// SellProceeds gives the KRW that is received for selling qty at price after the fee,
+// the quantity is first rounded to what korbit accepts.
+func SellProceeds(pair string, qty float64, price int64, feeRate float64) (int64, error) {
+	p, err := PrecisionFor(pair)
+	if err != nil {
+		return 0, err
+	}
+
+	gross := p.FloorQty(qty) * float64(price)
+	return int64(math.Floor(gross*(1-feeRate) + roundingSlack)), nil
+}
+
+// BreakEvenSellPrice gives the lowest price that a position bought at entry can be sold
+// at without losing money to the buy and sell fees, rounded up to the tick.
+func BreakEvenSellPrice(pair string, entry int64, buyFee, sellFee float64) (int64, error) {
+	p, err := PrecisionFor(pair)
+	if err != nil {
+		return 0, err
+	}
+	if sellFee >= 1 {
+		return 0, errors.New("sell fee must be below 1")
+	}
+
+	price := float64(entry) * (1 + buyFee) / (1 - sellFee)
+	return p.CeilPrice(price), nil
+}
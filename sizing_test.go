@@ -0,0 +1,66 @@
+package korbit
+
+import (
+	"testing"
+)
+
+func TestMaxBuyQty(t *testing.T) {
+	qty, err := MaxBuyQty(BTCKRW, 1002000, 10000000, 0.002)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if qty != 0.1 {
+		t.Errorf("got %v, want 0.1", qty)
+	}
+
+	b := Balances{KRW: {Available: 300000}}
+	qty, err = MaxBuyQtyFromBalances(b, XRPKRW, 700, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if qty != 428.571428 {
+		t.Errorf("got %v, want 428.571428", qty)
+	}
+
+	_, err = MaxBuyQty("doge_krw", 1000, 10, 0)
+	if err == nil {
+		t.Error("expected error for unknown pair")
+	}
+}
+
+func TestSellProceeds(t *testing.T) {
+	krw, err := SellProceeds(ETHKRW, 0.3, 500000, 0.002)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if krw != 149700 {
+		t.Errorf("got %d, want 149700", krw)
+	}
+
+	qty, err := MaxSellQty(Balances{ETH: {Available: 1.123456789}}, ETHKRW)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if qty != 1.12345678 {
+		t.Errorf("got %v, want 1.12345678", qty)
+	}
+}
+
+func TestBreakEvenSellPrice(t *testing.T) {
+	price, err := BreakEvenSellPrice(BTCKRW, 10000000, 0.002, 0.002)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 10000000 * 1.002 / 0.998 = 10040080.16 which goes up to the next 500 tick.
+	if price != 10040500 {
+		t.Errorf("got %d, want 10040500", price)
+	}
+
+	price, err = BreakEvenSellPrice(XRPKRW, 1000, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if price != 1000 {
+		t.Errorf("got %d, want 1000", price)
+	}
+}
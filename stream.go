@@ -0,0 +1,404 @@
+package korbit
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/pkg/errors"
+)
+
+// StreamURL is the url of the korbit websocket api.
+var StreamURL = "wss://ws.korbit.co.kr/v1/user/push"
+
+// TickerChannel and the other channels here are the public websocket channels that can be
+// subscribed to per pair.
+const (
+	TickerChannel    = "ticker"
+	OrderbookChannel = "orderbook"
+	TradeChannel     = "transaction"
+)
+
+// wsMessage is the envelope of every message sent to and from the websocket api.
+type wsMessage struct {
+	AccessToken *string         `json:"accessToken,omitempty"`
+	Event       string          `json:"event"`
+	Timestamp   int64           `json:"timestamp"`
+	Data        json.RawMessage `json:"data"`
+}
+
+// wsLevel is a level of a pushed orderbook.
+type wsLevel struct {
+	Price  string `json:"price"`
+	Amount string `json:"amount"`
+}
+
+// wsPush is the data of a pushed message, it has the fields of every public channel.
+type wsPush struct {
+	Channel      string    `json:"channel"`
+	CurrencyPair string    `json:"currency_pair"`
+	Timestamp    int64     `json:"timestamp"`
+	Price        string    `json:"price"`
+	Amount       string    `json:"amount"`
+	Taker        string    `json:"taker"`
+	Asks         []wsLevel `json:"asks"`
+	Bids         []wsLevel `json:"bids"`
+}
+
+// Stream is a websocket client for the public channels. Decoded messages are delivered on
+// the typed channels, which must be read from or the stream stops reading. The connection
+// is redialed with a growing backoff whenever it drops and every subscription is sent
+// again after reconnecting.
+type Stream struct {
+	Tickers    chan *Prices
+	Orderbooks chan *Orderbook
+	Trades     chan Trade
+	Orders     chan OrderUpdate
+	Fills      chan OwnFill
+	Errors     chan error
+
+	URL        string
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+	AuthCheck  time.Duration // how often the token is checked while private channels are subscribed
+
+	api  *API
+	stop chan struct{}
+	done chan struct{}
+	once sync.Once
+
+	mu        sync.Mutex
+	started   bool
+	sessions  int
+	conn      *websocket.Conn
+	channels  map[string]map[string]bool // channel -> pairs
+	sentToken string                     // access token of the last subscription sent
+	lastFill  map[string]int64           // pair -> id of the newest fill delivered
+	fillsFrom map[string]time.Time       // pair -> when its fills were first subscribed to
+}
+
+// NewStream returns a stream for the api, nothing is dialed until Start is called.
+func NewStream(k *API) *Stream {
+	return &Stream{
+		Tickers:    make(chan *Prices, 64),
+		Orderbooks: make(chan *Orderbook, 64),
+		Trades:     make(chan Trade, 64),
+		Orders:     make(chan OrderUpdate, 64),
+		Fills:      make(chan OwnFill, 64),
+		Errors:     make(chan error, 1),
+		URL:        StreamURL,
+		MinBackoff: time.Second,
+		MaxBackoff: time.Minute,
+		AuthCheck:  time.Minute,
+		api:        k,
+		stop:       make(chan struct{}),
+		done:       make(chan struct{}),
+		channels:   map[string]map[string]bool{},
+		lastFill:   map[string]int64{},
+		fillsFrom:  map[string]time.Time{},
+	}
+}
+
+// Subscribe subscribes to the channel for the pairs. It can be called before or after
+// Start, when connected the subscription is sent straight away.
+func (s *Stream) Subscribe(channel string, pairs ...string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.channels[channel] == nil {
+		s.channels[channel] = map[string]bool{}
+	}
+	for _, p := range pairs {
+		s.channels[channel][p] = true
+		if _, ok := s.fillsFrom[p]; channel == MyFillChannel && !ok {
+			s.fillsFrom[p] = time.Now()
+		}
+	}
+
+	if s.conn == nil {
+		return nil
+	}
+	return s.send(s.conn, "korbit:subscribe", s.subscriptions())
+}
+
+// Start dials and keeps the stream connected in the background until Close.
+func (s *Stream) Start() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.started {
+		s.started = true
+		go s.run()
+	}
+}
+
+// Close stops the stream and closes the delivery channels.
+func (s *Stream) Close() error {
+	s.once.Do(func() {
+		close(s.stop)
+
+		s.mu.Lock()
+		if s.conn != nil {
+			s.conn.Close()
+		}
+		s.mu.Unlock()
+	})
+
+	s.mu.Lock()
+	started := s.started
+	s.mu.Unlock()
+
+	if started {
+		<-s.done
+	}
+	return nil
+}
+
+// subscriptions gives the channel names in the form korbit expects, like
+// ticker:btc_krw,eth_krw. It must be called with the lock held.
+func (s *Stream) subscriptions() []string {
+	var subs []string
+	for channel, pairs := range s.channels {
+		var names []string
+		for p := range pairs {
+			names = append(names, p)
+		}
+		if len(names) > 0 {
+			sort.Strings(names)
+			subs = append(subs, channel+":"+strings.Join(names, ","))
+		}
+	}
+	sort.Strings(subs)
+	return subs
+}
+
+// send writes a subscription event for the channels. It must be called with the lock
+// held so that writes to the connection do not interleave.
+func (s *Stream) send(conn *websocket.Conn, event string, channels []string) error {
+	data, err := json.Marshal(map[string][]string{"channels": channels})
+	if err != nil {
+		return errors.Wrap(err, "marshal korbit subscription")
+	}
+
+	msg := wsMessage{
+		Event:     event,
+		Timestamp: time.Now().UnixNano() / int64(time.Millisecond),
+		Data:      data,
+	}
+	if s.api != nil {
+		if token := s.api.CurrentToken(); token != nil {
+			msg.AccessToken = &token.AccessToken
+			s.sentToken = token.AccessToken
+		}
+	}
+
+	err = conn.WriteJSON(msg)
+	if err != nil {
+		return errors.Wrap(err, "write korbit subscription")
+	}
+	return nil
+}
+
+func (s *Stream) run() {
+	defer func() {
+		close(s.Tickers)
+		close(s.Orderbooks)
+		close(s.Trades)
+		close(s.Orders)
+		close(s.Fills)
+		close(s.done)
+	}()
+
+	backoff := s.MinBackoff
+	for {
+		err := s.session()
+		if s.stopped() {
+			return
+		}
+		if err != nil {
+			s.report(err)
+		} else {
+			backoff = s.MinBackoff
+		}
+
+		select {
+		case <-s.stop:
+			return
+		case <-time.After(backoff):
+		}
+
+		if err != nil {
+			backoff *= 2
+			if backoff > s.MaxBackoff {
+				backoff = s.MaxBackoff
+			}
+		}
+	}
+}
+
+// session dials, subscribes and reads until the connection drops. A nil error means the
+// connection was up and subscribed before it dropped, so the backoff can start over.
+func (s *Stream) session() error {
+	conn, _, err := websocket.DefaultDialer.Dial(s.URL, nil)
+	if err != nil {
+		return errors.Wrap(err, "dial korbit stream")
+	}
+
+	s.mu.Lock()
+	if s.stopped() {
+		s.mu.Unlock()
+		conn.Close()
+		return nil
+	}
+	s.conn = conn
+	s.sessions++
+	reconnect := s.sessions > 1
+	subs := s.subscriptions()
+	if len(subs) > 0 {
+		err = s.send(conn, "korbit:subscribe", subs)
+	}
+	s.mu.Unlock()
+
+	quit := make(chan struct{})
+	defer func() {
+		close(quit)
+		s.mu.Lock()
+		s.conn = nil
+		s.mu.Unlock()
+		conn.Close()
+	}()
+
+	if err != nil {
+		return err
+	}
+
+	go s.reauth(quit)
+	if reconnect {
+		s.backfill()
+	}
+
+	for {
+		var msg wsMessage
+		err := conn.ReadJSON(&msg)
+		if err != nil {
+			if s.stopped() {
+				return nil
+			}
+			s.report(errors.Wrap(err, "read korbit stream"))
+			return nil
+		}
+
+		err = s.dispatch(&msg)
+		if err != nil {
+			s.report(err)
+		}
+	}
+}
+
+// dispatch decodes a pushed message and delivers it on its channel.
+func (s *Stream) dispatch(msg *wsMessage) error {
+	if !strings.HasPrefix(msg.Event, "korbit:push") {
+		return nil
+	}
+
+	var push wsPush
+	err := json.Unmarshal(msg.Data, &push)
+	if err != nil {
+		return errors.Wrapf(err, "decode korbit %s", msg.Event)
+	}
+
+	switch push.Channel {
+	case TickerChannel:
+		var prices Prices
+		err = json.Unmarshal(msg.Data, &prices)
+		if err != nil {
+			return errors.Wrap(err, "decode korbit ticker push")
+		}
+		prices.CurrencyPair = push.CurrencyPair
+		prices.setTimestamp(s.api.location())
+
+		select {
+		case s.Tickers <- &prices:
+		case <-s.stop:
+		}
+
+	case OrderbookChannel:
+		resp := OrderbookResp{Timestamp: push.Timestamp}
+		for _, l := range push.Bids {
+			resp.Bids = append(resp.Bids, []string{l.Price, l.Amount})
+		}
+		for _, l := range push.Asks {
+			resp.Asks = append(resp.Asks, []string{l.Price, l.Amount})
+		}
+
+		book, err := resp.Transform()
+		if err != nil {
+			return errors.Wrap(err, "transform korbit orderbook push")
+		}
+		book.CurrencyPair = push.CurrencyPair
+
+		select {
+		case s.Orderbooks <- book:
+		case <-s.stop:
+		}
+
+	case TradeChannel:
+		trade, err := push.trade()
+		if err != nil {
+			return err
+		}
+
+		select {
+		case s.Trades <- trade:
+		case <-s.stop:
+		}
+
+	case MyOrderChannel, MyFillChannel:
+		return s.dispatchPrivate(push.Channel, msg.Data)
+	}
+
+	return nil
+}
+
+// trade turns a pushed transaction into a Trade.
+func (p *wsPush) trade() (Trade, error) {
+	var price flexFloat
+	err := price.UnmarshalJSON([]byte(p.Price))
+	if err != nil {
+		return Trade{}, errors.Wrap(err, "korbit trade push price")
+	}
+
+	var amount flexFloat
+	err = amount.UnmarshalJSON([]byte(p.Amount))
+	if err != nil {
+		return Trade{}, errors.Wrap(err, "korbit trade push amount")
+	}
+
+	return Trade{
+		CurrencyPair: p.CurrencyPair,
+		Timestamp:    p.Timestamp,
+		Price:        int64(price),
+		Amount:       float64(amount),
+		Side:         p.Taker,
+	}, nil
+}
+
+// report sends the error on Errors unless one is already waiting to be read.
+func (s *Stream) report(err error) {
+	select {
+	case s.Errors <- err:
+	default:
+	}
+}
+
+func (s *Stream) stopped() bool {
+	select {
+	case <-s.stop:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,104 @@
+package korbit
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// newStreamServer starts a websocket stand-in for korbit. Every connection is passed to
+// serve together with the channels of its first subscription.
+func newStreamServer(t *testing.T, serve func(conn *websocket.Conn, channels []string)) string {
+	upgrader := websocket.Upgrader{}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer conn.Close()
+
+		var sub struct {
+			Event string `json:"event"`
+			Data  struct {
+				Channels []string `json:"channels"`
+			} `json:"data"`
+		}
+		err = conn.ReadJSON(&sub)
+		if err != nil {
+			return
+		}
+		if sub.Event != "korbit:subscribe" {
+			t.Errorf("unexpected event: %s", sub.Event)
+		}
+
+		serve(conn, sub.Data.Channels)
+	}))
+	t.Cleanup(srv.Close)
+
+	return "ws" + strings.TrimPrefix(srv.URL, "http")
+}
+
+func push(conn *websocket.Conn, channel, data string) error {
+	msg := fmt.Sprintf(`{"event": "korbit:push-%s", "timestamp": 1558590089274,
+		"data": {"channel": "%s", "currency_pair": "btc_krw", "timestamp": 1558590089274, %s}}`,
+		channel, channel, data)
+	return conn.WriteMessage(websocket.TextMessage, []byte(msg))
+}
+
+func TestStream(t *testing.T) {
+	subscribed := make(chan []string, 2)
+	url := newStreamServer(t, func(conn *websocket.Conn, channels []string) {
+		subscribed <- channels
+
+		push(conn, TickerChannel, `"last": "9198500", "bid": "9192500", "ask": "9198000"`)
+		push(conn, OrderbookChannel, `"bids": [{"price": "9192500", "amount": "0.5"}],
+			"asks": [{"price": "9198000", "amount": "1.25"}]`)
+		push(conn, TradeChannel, `"price": "9198000", "amount": "0.01", "taker": "buy"`)
+		// dropping the connection makes the stream reconnect and subscribe again.
+	})
+
+	s := NewStream(nil)
+	s.URL = url
+	s.MinBackoff = 10 * time.Millisecond
+	s.Subscribe(TickerChannel, ETHKRW, BTCKRW)
+	s.Subscribe(OrderbookChannel, BTCKRW)
+	s.Subscribe(TradeChannel, BTCKRW)
+	s.Start()
+	defer s.Close()
+
+	want := "orderbook:btc_krw ticker:btc_krw,eth_krw transaction:btc_krw"
+	if got := strings.Join(<-subscribed, " "); got != want {
+		t.Errorf("subscribed to %s, want %s", got, want)
+	}
+
+	ticker := <-s.Tickers
+	if ticker.CurrencyPair != BTCKRW || ticker.Last != 9198500 || ticker.Timestamp.IsZero() {
+		t.Errorf("unexpected ticker: %+v", ticker)
+	}
+
+	book := <-s.Orderbooks
+	if book.CurrencyPair != BTCKRW || book.Bids[0].Price != 9192500 || book.Asks[0].Qty != 1.25 {
+		t.Errorf("unexpected orderbook: %+v", book)
+	}
+
+	trade := <-s.Trades
+	if trade.Price != 9198000 || trade.Amount != 0.01 || trade.Side != Buy {
+		t.Errorf("unexpected trade: %+v", trade)
+	}
+
+	select {
+	case channels := <-subscribed:
+		if strings.Join(channels, " ") != want {
+			t.Errorf("resubscribed to %v", channels)
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("stream did not reconnect")
+	}
+}
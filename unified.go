@@ -0,0 +1,391 @@
+package korbit
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/shopspring/decimal"
+)
+
+// Exchange is the venue neutral view of an exchange that cross venue code can trade
+// through. Symbols are written as BASE/QUOTE, like BTC/KRW, and every amount is a decimal
+// so that nothing is lost between venues with different precisions.
+type Exchange interface {
+	Name() string
+	Ticker(symbol Symbol) (*Ticker, error)
+	Book(symbol Symbol) (*Book, error)
+	Assets() (map[string]Asset, error)
+	PlaceOrder(req OrderRequest) (*Order, error)
+	CancelOrder(symbol Symbol, id string) error
+	OpenOrders(symbol Symbol) ([]Order, error)
+	Fills(symbol Symbol, limit int) ([]Execution, error)
+}
+
+var _ Exchange = (*API)(nil)
+
+// Symbol is a market written as BASE/QUOTE in upper case, like BTC/KRW.
+type Symbol string
+
+// SymbolOf gives the symbol of a korbit currency pair like btc_krw.
+func SymbolOf(pair string) Symbol {
+	coin, fiat := PairCurrencies(pair)
+	return Symbol(strings.ToUpper(coin) + "/" + strings.ToUpper(fiat))
+}
+
+// Base gives the currency that is bought and sold, BTC for BTC/KRW.
+func (s Symbol) Base() string {
+	i := strings.Index(string(s), "/")
+	if i < 0 {
+		return ""
+	}
+	return string(s)[:i]
+}
+
+// Quote gives the currency that prices are in, KRW for BTC/KRW.
+func (s Symbol) Quote() string {
+	i := strings.Index(string(s), "/")
+	if i < 0 {
+		return ""
+	}
+	return string(s)[i+1:]
+}
+
+// Pair gives the korbit currency pair of the symbol, btc_krw for BTC/KRW.
+func (s Symbol) Pair() (string, error) {
+	if s.Base() == "" || s.Quote() == "" {
+		return "", errors.Errorf("malformed symbol: %s", s)
+	}
+
+	pair := strings.ToLower(s.Base()) + "_" + strings.ToLower(s.Quote())
+	if _, ok := Precisions[pair]; !ok {
+		return "", errors.Errorf("korbit does not list %s", s)
+	}
+	return pair, nil
+}
+
+// Side is the side of an order or fill.
+type Side string
+
+// BuySide and SellSide are the sides of an order.
+const (
+	BuySide  Side = "buy"
+	SellSide Side = "sell"
+)
+
+// OrderType is the type of an order.
+type OrderType string
+
+// LimitOrder and MarketOrder are the order types.
+const (
+	LimitOrder  OrderType = "limit"
+	MarketOrder OrderType = "market"
+)
+
+// OrderOpen and the other statuses here are the statuses of an Order.
+const (
+	OrderOpen     = "open"
+	OrderFilled   = "filled"
+	OrderCanceled = "canceled"
+)
+
+// Ticker is the best prices and the daily volume of a market.
+type Ticker struct {
+	Symbol Symbol
+	Bid    decimal.Decimal
+	Ask    decimal.Decimal
+	Last   decimal.Decimal
+	Volume decimal.Decimal
+	Time   time.Time
+}
+
+// BookLevel is a price level of a Book.
+type BookLevel struct {
+	Price decimal.Decimal
+	Qty   decimal.Decimal
+}
+
+// Book is the orderbook of a market, bids best first and asks best first.
+type Book struct {
+	Symbol Symbol
+	Bids   []BookLevel
+	Asks   []BookLevel
+	Time   time.Time
+}
+
+// Asset is the balance of a currency. Locked is what is held by orders and withdrawals.
+type Asset struct {
+	Currency string
+	Free     decimal.Decimal
+	Locked   decimal.Decimal
+}
+
+// OrderRequest is an order to place. Limit orders need Price and Qty, market sells need
+// Qty and market buys need Notional, the amount of the quote currency to spend.
+type OrderRequest struct {
+	Symbol   Symbol
+	Side     Side
+	Type     OrderType
+	Price    decimal.Decimal
+	Qty      decimal.Decimal
+	Notional decimal.Decimal
+}
+
+// Order is an order on the exchange.
+type Order struct {
+	ID     string
+	Symbol Symbol
+	Side   Side
+	Type   OrderType
+	Price  decimal.Decimal
+	Qty    decimal.Decimal
+	Filled decimal.Decimal
+	Status string
+	Time   time.Time
+}
+
+// Execution is a fill of one of the account's orders.
+type Execution struct {
+	ID          string
+	OrderID     string
+	Symbol      Symbol
+	Side        Side
+	Price       decimal.Decimal
+	Qty         decimal.Decimal
+	Fee         decimal.Decimal
+	FeeCurrency string
+	Time        time.Time
+}
+
+// Name gives the name of the exchange.
+func (k *API) Name() string {
+	return "korbit"
+}
+
+// Ticker gets the ticker of the symbol.
+func (k *API) Ticker(symbol Symbol) (*Ticker, error) {
+	pair, err := symbol.Pair()
+	if err != nil {
+		return nil, err
+	}
+
+	prices, err := k.GetPrices(pair)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Ticker{
+		Symbol: symbol,
+		Bid:    decimal.NewFromInt(prices.Bid),
+		Ask:    decimal.NewFromInt(prices.Ask),
+		Last:   decimal.NewFromInt(prices.Last),
+		Volume: decimal.NewFromFloat(prices.Volume),
+		Time:   prices.Timestamp,
+	}, nil
+}
+
+// Book gets the orderbook of the symbol.
+func (k *API) Book(symbol Symbol) (*Book, error) {
+	pair, err := symbol.Pair()
+	if err != nil {
+		return nil, err
+	}
+
+	orderbook, err := k.GetOrderbook(pair)
+	if err != nil {
+		return nil, err
+	}
+
+	levels := func(orders []OrderbookOrder) []BookLevel {
+		ret := make([]BookLevel, len(orders))
+		for i, o := range orders {
+			ret[i] = BookLevel{Price: decimal.NewFromInt(o.Price), Qty: decimal.NewFromFloat(o.Qty)}
+		}
+		return ret
+	}
+
+	return &Book{
+		Symbol: symbol,
+		Bids:   levels(orderbook.Bids),
+		Asks:   levels(orderbook.Asks),
+		Time:   millisToTime(orderbook.Timestamp).In(k.location()),
+	}, nil
+}
+
+// Assets gets the balances keyed by the upper case currency.
+func (k *API) Assets() (map[string]Asset, error) {
+	balances, err := k.GetBalances()
+	if err != nil {
+		return nil, err
+	}
+
+	assets := map[string]Asset{}
+	for currency, b := range balances {
+		c := strings.ToUpper(currency)
+		assets[c] = Asset{
+			Currency: c,
+			Free:     decimal.NewFromFloat(b.Available),
+			Locked:   decimal.NewFromFloat(b.TradeInuse).Add(decimal.NewFromFloat(b.WithdrawalInUse)),
+		}
+	}
+
+	return assets, nil
+}
+
+// PlaceOrder places the order. Korbit prices are whole KRW so limit prices with a
+// fraction are refused rather than rounded.
+func (k *API) PlaceOrder(req OrderRequest) (*Order, error) {
+	pair, err := req.Symbol.Pair()
+	if err != nil {
+		return nil, err
+	}
+
+	args := OrderArgs{CurrencyPair: pair, Type: string(req.Type)}
+	switch req.Type {
+	case LimitOrder:
+		if !req.Price.IsPositive() || !req.Qty.IsPositive() {
+			return nil, errors.New("limit orders need a positive price and qty")
+		}
+		if !req.Price.Equal(req.Price.Truncate(0)) {
+			return nil, errors.Errorf("korbit prices are whole %s: %s", req.Symbol.Quote(), req.Price)
+		}
+		args.Price = req.Price.IntPart()
+		args.CoinAmount = req.Qty.String()
+	case MarketOrder:
+		if req.Side == BuySide {
+			if !req.Notional.IsPositive() {
+				return nil, errors.New("market buys need a positive notional")
+			}
+			args.FiatAmount = req.Notional.String()
+		} else {
+			if !req.Qty.IsPositive() {
+				return nil, errors.New("market sells need a positive qty")
+			}
+			args.CoinAmount = req.Qty.String()
+		}
+	default:
+		return nil, errors.Errorf("unrecognized order type: %s", req.Type)
+	}
+
+	var resp *OrderResponse
+	switch req.Side {
+	case BuySide:
+		resp, err = k.Buy(&args)
+	case SellSide:
+		resp, err = k.Sell(&args)
+	default:
+		return nil, errors.Errorf("unrecognized side: %s", req.Side)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &Order{
+		ID:     strconv.FormatInt(resp.OrderID, 10),
+		Symbol: req.Symbol,
+		Side:   req.Side,
+		Type:   req.Type,
+		Price:  req.Price,
+		Qty:    req.Qty,
+		Filled: decimal.Zero,
+		Status: OrderOpen,
+		Time:   time.Now(),
+	}, nil
+}
+
+// CancelOrder cancels the order with the id.
+func (k *API) CancelOrder(symbol Symbol, id string) error {
+	pair, err := symbol.Pair()
+	if err != nil {
+		return err
+	}
+
+	orderID, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return errors.Wrapf(err, "korbit order id %s", id)
+	}
+
+	resps, err := k.CancelOpenOrders([]int64{orderID}, pair)
+	if err != nil {
+		return err
+	}
+
+	for _, r := range resps {
+		if r.OrderID != orderID {
+			continue
+		}
+		if r.Status != Success {
+			return errors.Errorf("cancel of order %s not successful: %s", id, r.Status)
+		}
+		return nil
+	}
+
+	return errors.Errorf("cancel of order %s has no result", id)
+}
+
+// OpenOrders lists the open orders of the symbol.
+func (k *API) OpenOrders(symbol Symbol) ([]Order, error) {
+	pair, err := symbol.Pair()
+	if err != nil {
+		return nil, err
+	}
+
+	resps, err := k.ListOpenOrders(pair)
+	if err != nil {
+		return nil, err
+	}
+
+	orders := make([]Order, 0, len(*resps))
+	for _, r := range *resps {
+		side := BuySide
+		if r.Type == Ask || r.Type == Sell {
+			side = SellSide
+		}
+
+		total := decimal.NewFromFloat(r.Total.Value)
+		orders = append(orders, Order{
+			ID:     strconv.FormatInt(r.ID, 10),
+			Symbol: symbol,
+			Side:   side,
+			Type:   LimitOrder,
+			Price:  decimal.NewFromFloat(r.Price.Value),
+			Qty:    total,
+			Filled: total.Sub(decimal.NewFromFloat(r.Open.Value)),
+			Status: OrderOpen,
+			Time:   millisToTime(r.Timestamp).In(k.location()),
+		})
+	}
+
+	return orders, nil
+}
+
+// Fills gets the newest fills of the symbol, at most limit of them.
+func (k *API) Fills(symbol Symbol, limit int) ([]Execution, error) {
+	pair, err := symbol.Pair()
+	if err != nil {
+		return nil, err
+	}
+
+	fills, err := k.GetFills(pair, "", strconv.Itoa(limit), "")
+	if err != nil {
+		return nil, err
+	}
+
+	executions := make([]Execution, 0, len(fills))
+	for _, f := range fills {
+		executions = append(executions, Execution{
+			ID:          strconv.FormatInt(f.ID, 10),
+			OrderID:     strconv.FormatInt(f.FillsDetail.OrderID, 10),
+			Symbol:      symbol,
+			Side:        Side(f.Type),
+			Price:       decimal.NewFromFloat(f.FillsDetail.Price.Value),
+			Qty:         decimal.NewFromFloat(f.FillsDetail.Amount.Value),
+			Fee:         decimal.NewFromFloat(f.Fee.Value),
+			FeeCurrency: strings.ToUpper(f.Fee.Currency),
+			Time:        millisToTime(f.Timestamp).In(k.location()),
+		})
+	}
+
+	return executions, nil
+}
@@ -0,0 +1,121 @@
+package korbit
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/shopspring/decimal"
+)
+
+func TestSymbol(t *testing.T) {
+	s := SymbolOf(XRPKRW)
+	if s != "XRP/KRW" || s.Base() != "XRP" || s.Quote() != "KRW" {
+		t.Errorf("unexpected symbol: %s", s)
+	}
+
+	pair, err := s.Pair()
+	if err != nil || pair != XRPKRW {
+		t.Errorf("unexpected pair: %s %v", pair, err)
+	}
+
+	for _, bad := range []Symbol{"BTCKRW", "DOGE/KRW", "/KRW"} {
+		if _, err := bad.Pair(); err == nil {
+			t.Errorf("expected error for %s", bad)
+		}
+	}
+}
+
+func TestExchangeBookAndAssets(t *testing.T) {
+	newTestAPI(t, &GetOrderbook, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"timestamp": 1500000000000, "bids": [["3000000", "0.1", "1"]],
+			"asks": [["3000500", "0.25", "2"]]}`)
+	})
+	k := newTestAPI(t, &BalancesURL, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"krw": {"available": "1000", "trade_in_use": "500", "withdrawal_in_use": "0"},
+			"btc": {"available": "0.1", "trade_in_use": "0", "withdrawal_in_use": "0.2"}}`)
+	})
+
+	var ex Exchange = k
+	book, err := ex.Book("BTC/KRW")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(book.Bids) != 1 || !book.Bids[0].Price.Equal(decimal.NewFromInt(3000000)) ||
+		!book.Asks[0].Qty.Equal(decimal.RequireFromString("0.25")) {
+		t.Errorf("unexpected book: %+v", book)
+	}
+
+	assets, err := ex.Assets()
+	if err != nil {
+		t.Fatal(err)
+	}
+	btc := assets["BTC"]
+	if !btc.Free.Equal(decimal.RequireFromString("0.1")) || !btc.Locked.Equal(decimal.RequireFromString("0.2")) {
+		t.Errorf("unexpected btc asset: %+v", btc)
+	}
+	if !assets["KRW"].Locked.Equal(decimal.NewFromInt(500)) {
+		t.Errorf("unexpected krw asset: %+v", assets["KRW"])
+	}
+}
+
+func TestExchangeOrders(t *testing.T) {
+	newTestAPI(t, &PlaceAsk, func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		if r.Form.Get("price") != "3000500" || r.Form.Get("coin_amount") != "0.125" ||
+			r.Form.Get("currency_pair") != BTCKRW {
+			t.Errorf("unexpected order form: %v", r.Form)
+		}
+		fmt.Fprint(w, `{"orderId": 58, "status": "success", "currency_pair": "btc_krw"}`)
+	})
+	newTestAPI(t, &ListOpenOrders, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `[{"timestamp": 1500000000000, "id": "58", "type": "ask",
+			"price": {"currency": "krw", "value": "3000500"},
+			"total": {"currency": "btc", "value": "0.125"},
+			"open": {"currency": "btc", "value": "0.1"}}]`)
+	})
+	k := newTestAPI(t, &CancelOpenOrders, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `[{"orderId": "58", "status": "not_found"}]`)
+	})
+
+	order, err := k.PlaceOrder(OrderRequest{
+		Symbol: "BTC/KRW",
+		Side:   SellSide,
+		Type:   LimitOrder,
+		Price:  decimal.NewFromInt(3000500),
+		Qty:    decimal.RequireFromString("0.125"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if order.ID != "58" || order.Status != OrderOpen {
+		t.Errorf("unexpected order: %+v", order)
+	}
+
+	_, err = k.PlaceOrder(OrderRequest{
+		Symbol: "BTC/KRW",
+		Side:   BuySide,
+		Type:   LimitOrder,
+		Price:  decimal.RequireFromString("3000000.5"),
+		Qty:    decimal.NewFromInt(1),
+	})
+	if err == nil {
+		t.Error("expected error for a fractional krw price")
+	}
+
+	orders, err := k.OpenOrders("BTC/KRW")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(orders) != 1 || orders[0].Side != SellSide ||
+		!orders[0].Filled.Equal(decimal.RequireFromString("0.025")) {
+		t.Errorf("unexpected open orders: %+v", orders)
+	}
+
+	if k.CancelOrder("BTC/KRW", "58") == nil {
+		t.Error("expected error for an unsuccessful cancel")
+	}
+	if k.CancelOrder("BTC/KRW", "59") == nil {
+		t.Error("expected error for a cancel without a result for the order")
+	}
+}
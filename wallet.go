@@ -2,7 +2,10 @@ package korbit
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
+	"net/url"
+	"strconv"
 
 	"github.com/pkg/errors"
 )
@@ -21,11 +24,12 @@ type ConnectedAccount struct {
 	Address         AcctInfo `json:"address"`
 }
 
-// AcctInfo is nested inside korbit wallets
+// AcctInfo is nested inside korbit wallets. For coins Owner holds the coin address.
 type AcctInfo struct {
-	Bank    string `json:"bank"`
-	Account string `json:"account"`
-	Owner   string `json:"address"`
+	Bank           string `json:"bank"`
+	Account        string `json:"account"`
+	Owner          string `json:"address"`
+	DestinationTag string `json:"destination_tag"`
 }
 
 // NonBtcWallet is for querying non btc currencies which are different than btc
@@ -79,3 +83,316 @@ func (k *API) GetBalances() (balances Balances, err error) {
 
 	return balances, nil
 }
+
+// withdrawableCoins are the coins that can be sent out of korbit.
+var withdrawableCoins = map[string]bool{BTC: true, ETH: true, ETC: true, XRP: true}
+
+// WithdrawalResponse is the response to requesting or cancelling a coin withdrawal.
+type WithdrawalResponse struct {
+	TransferID int64  `json:"transferId,string"`
+	Status     string `json:"status"`
+}
+
+// TransferDetails are the details of where a coin transfer went.
+type TransferDetails struct {
+	TransactionID  string `json:"transaction_id"`
+	Address        string `json:"address"`
+	DestinationTag string `json:"destination_tag"`
+}
+
+// CoinIn and CoinOut are the types of coin deposits and withdrawals.
+const (
+	CoinIn  = "coin-in"
+	CoinOut = "coin-out"
+)
+
+// CoinTransferStatus is the status of a coin deposit or withdrawal.
+type CoinTransferStatus struct {
+	ID            int64           `json:"id,string"`
+	Type          string          `json:"type"`
+	Currency      string          `json:"currency"`
+	Amount        float64         `json:"amount,string"`
+	Fee           float64         `json:"fee,string"`
+	Status        string          `json:"status"`
+	CreatedAt     int64           `json:"createdAt"`
+	UpdatedAt     int64           `json:"updatedAt"`
+	CompletedAt   int64           `json:"completedAt"`
+	Confirmations int             `json:"confirmations"`
+	Details       TransferDetails `json:"details"`
+}
+
+// RequestCoinWithdrawal sends amount of the coin to the address. The destination tag is
+// only used for XRP and can be left empty.
+func (k *API) RequestCoinWithdrawal(currency string, amount float64, address, destinationTag string) (
+	*WithdrawalResponse, error) {
+
+	if !withdrawableCoins[currency] {
+		return nil, errors.Errorf("%s can not be withdrawn", currency)
+	}
+	if !validAmount(amount) {
+		return nil, errors.New("withdrawal amount must be a positive number")
+	}
+	if address == "" {
+		return nil, errors.New("withdrawal address must be given")
+	}
+	if destinationTag != "" && currency != XRP {
+		return nil, errors.Errorf("destination tags are not used for %s", currency)
+	}
+
+	data := url.Values{
+		"currency":     {currency},
+		"amount":       {strconv.FormatFloat(amount, 'f', -1, 64)},
+		"address":      {address},
+		"fee_priority": {"normal"},
+		"nonce":        {k.GetNonce()},
+	}
+	if destinationTag != "" {
+		data.Set("destination_tag", destinationTag)
+	}
+
+	return k.postWithdrawal(BtcWithdrawal, data)
+}
+
+// CancelCoinWithdrawal cancels a withdrawal that has not been sent yet.
+func (k *API) CancelCoinWithdrawal(currency string, id int64) (*WithdrawalResponse, error) {
+	data := url.Values{
+		"currency": {currency},
+		"id":       {fmt.Sprintf("%d", id)},
+		"nonce":    {k.GetNonce()},
+	}
+
+	resp, err := k.postWithdrawal(CancelWithdrawal, data)
+	if resp != nil && resp.TransferID == 0 {
+		resp.TransferID = id
+	}
+	return resp, err
+}
+
+// postWithdrawal posts the withdrawal request and checks that it succeeded.
+func (k *API) postWithdrawal(endpoint string, data url.Values) (*WithdrawalResponse, error) {
+	req, err := k.NewRequest(endpoint, "POST", data)
+	if err != nil {
+		return nil, errors.Wrap(err, "make korbit withdrawal request")
+	}
+
+	resp, err := k.Client.Do(req)
+	if err != nil {
+		return nil, errors.Wrap(err, "placing korbit withdrawal")
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("status: %d Header: %v", resp.StatusCode, resp.Header)
+	}
+
+	var withdrawal WithdrawalResponse
+	err = json.NewDecoder(resp.Body).Decode(&withdrawal)
+	if err != nil {
+		return nil, errors.Wrap(err, "json decode korbit withdrawal")
+	}
+
+	if withdrawal.Status != Success {
+		return &withdrawal, errors.Errorf("withdrawal not successful: %s", withdrawal.Status)
+	}
+
+	return &withdrawal, nil
+}
+
+// QueryCoinWithdrawal gets the status of a withdrawal.
+func (k *API) QueryCoinWithdrawal(currency string, id int64) (*CoinTransferStatus, error) {
+	return k.queryCoinTransfer(currency, CoinOut, id)
+}
+
+// queryCoinTransfer gets the status of the transfer with the id, which must be of the
+// type so that a deposit is not taken for a withdrawal or the other way around.
+func (k *API) queryCoinTransfer(currency, typ string, id int64) (*CoinTransferStatus, error) {
+	transfers, err := k.coinTransfers(currency, id)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range transfers {
+		if transfers[i].ID != id {
+			continue
+		}
+		if transfers[i].Type != typ {
+			return nil, errors.Errorf("%s transfer %d is a %s, not a %s", currency, id,
+				transfers[i].Type, typ)
+		}
+		return &transfers[i], nil
+	}
+
+	return nil, errors.Errorf("no %s transfer with id %d", currency, id)
+}
+
+// coinTransfers gets the transfers of the coin, only the one with the id if it is given.
+func (k *API) coinTransfers(currency string, id int64) ([]CoinTransferStatus, error) {
+	url := fmt.Sprintf("%s?currency=%s", CoinStatus, currency)
+	if id != 0 {
+		url = fmt.Sprintf("%s&id=%d", url, id)
+	}
+
+	req, err := k.NewRequest(url, "GET", nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "make korbit coin status request")
+	}
+
+	resp, err := k.Client.Do(req)
+	if err != nil {
+		return nil, errors.Wrap(err, "getting korbit coin status")
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("status: %d Header: %v", resp.StatusCode, resp.Header)
+	}
+
+	var transfers []CoinTransferStatus
+	err = json.NewDecoder(resp.Body).Decode(&transfers)
+	if err != nil {
+		return nil, errors.Wrap(err, "json decode korbit coin status")
+	}
+
+	return transfers, nil
+}
+
+// DepositAddress is an address that coins can be deposited to. DestinationTag is only set
+// for XRP.
+type DepositAddress struct {
+	Currency       string `json:"currency"`
+	Address        string `json:"address"`
+	DestinationTag string `json:"destination_tag"`
+}
+
+// GetWallet gets the wallet of the pair, which has the connected deposit and withdrawal
+// accounts along with the balances.
+func (k *API) GetWallet(pair string) (*Wallet, error) {
+	url := fmt.Sprintf("%s?currency_pair=%s", WalletURL, pair)
+	req, err := k.NewRequest(url, "GET", nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "making korbit wallet request")
+	}
+
+	resp, err := k.Client.Do(req)
+	if err != nil {
+		return nil, errors.Wrapf(err, "getting korbit wallet for %s", pair)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("status: %d Header: %v", resp.StatusCode, resp.Header)
+	}
+
+	var wallet Wallet
+	err = json.NewDecoder(resp.Body).Decode(&wallet)
+	if err != nil {
+		return nil, errors.Wrapf(err, "korbit wallet unmarshal failed for %s", pair)
+	}
+
+	return &wallet, nil
+}
+
+// GetDepositAddresses gets the assigned deposit address of every coin, keyed by the
+// currency. Coins that have no address assigned yet are left out.
+func (k *API) GetDepositAddresses() (map[string]DepositAddress, error) {
+	addresses := map[string]DepositAddress{}
+
+	for coin := range withdrawableCoins {
+		wallet, err := k.GetWallet(coin + "_" + KRW)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, acct := range wallet.In {
+			if acct.Currency != coin || acct.Address.Owner == "" {
+				continue
+			}
+			addresses[coin] = DepositAddress{
+				Currency:       coin,
+				Address:        acct.Address.Owner,
+				DestinationTag: acct.Address.DestinationTag,
+			}
+		}
+	}
+
+	return addresses, nil
+}
+
+// AssignDepositAddress asks korbit to assign a deposit address for the coin and returns
+// it.
+func (k *API) AssignDepositAddress(currency string) (*DepositAddress, error) {
+	if !withdrawableCoins[currency] {
+		return nil, errors.Errorf("%s has no deposit addresses", currency)
+	}
+
+	data := url.Values{
+		"currency": {currency},
+		"nonce":    {k.GetNonce()},
+	}
+
+	req, err := k.NewRequest(AssignAddress, "POST", data)
+	if err != nil {
+		return nil, errors.Wrap(err, "make korbit assign address request")
+	}
+
+	resp, err := k.Client.Do(req)
+	if err != nil {
+		return nil, errors.Wrap(err, "placing korbit assign address")
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("status: %d Header: %v", resp.StatusCode, resp.Header)
+	}
+
+	var assigned struct {
+		Status string `json:"status"`
+		DepositAddress
+	}
+	err = json.NewDecoder(resp.Body).Decode(&assigned)
+	if err != nil {
+		return nil, errors.Wrap(err, "json decode korbit assign address")
+	}
+
+	if assigned.Status != Success {
+		return nil, errors.Errorf("address assignment not successful: %s", assigned.Status)
+	}
+
+	// the address is not always sent back, in which case it is read from the wallet.
+	if assigned.Address == "" {
+		addresses, err := k.GetDepositAddresses()
+		if err != nil {
+			return nil, err
+		}
+		addr, ok := addresses[currency]
+		if !ok {
+			return nil, errors.Errorf("no %s address after assignment", currency)
+		}
+		return &addr, nil
+	}
+
+	assigned.Currency = currency
+	return &assigned.DepositAddress, nil
+}
+
+// QueryCoinDeposit gets the status of a deposit, including how many confirmations it has.
+func (k *API) QueryCoinDeposit(currency string, id int64) (*CoinTransferStatus, error) {
+	return k.queryCoinTransfer(currency, CoinIn, id)
+}
+
+// PendingCoinDeposits gets the deposits of the coin that have not completed yet.
+func (k *API) PendingCoinDeposits(currency string) ([]CoinTransferStatus, error) {
+	transfers, err := k.coinTransfers(currency, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	var pending []CoinTransferStatus
+	for _, t := range transfers {
+		if t.Type == CoinIn && t.CompletedAt == 0 {
+			pending = append(pending, t)
+		}
+	}
+
+	return pending, nil
+}
@@ -1,6 +1,9 @@
 package korbit
 
 import (
+	"fmt"
+	"math"
+	"net/http"
 	"testing"
 )
 
@@ -11,3 +14,137 @@ func TestGetWallets(t *testing.T) {
 	}
 	println(balances)
 }
+
+func TestRequestCoinWithdrawal(t *testing.T) {
+	k := newTestAPI(t, &BtcWithdrawal, func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		if r.Form.Get("currency") != XRP || r.Form.Get("amount") != "25.5" ||
+			r.Form.Get("address") != "rAddress" || r.Form.Get("destination_tag") != "1234" {
+			t.Errorf("unexpected withdrawal form: %v", r.Form)
+		}
+		fmt.Fprint(w, `{"transferId": "270", "status": "success"}`)
+	})
+
+	resp, err := k.RequestCoinWithdrawal(XRP, 25.5, "rAddress", "1234")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.TransferID != 270 {
+		t.Errorf("unexpected transfer id: %d", resp.TransferID)
+	}
+
+	_, err = k.RequestCoinWithdrawal(BTC, 1, "1Address", "1234")
+	if err == nil {
+		t.Error("expected error for a destination tag on btc")
+	}
+	_, err = k.RequestCoinWithdrawal(KRW, 1, "1Address", "")
+	if err == nil {
+		t.Error("expected error for withdrawing krw as a coin")
+	}
+	for _, amount := range []float64{0, -1, math.NaN(), math.Inf(1)} {
+		_, err = k.RequestCoinWithdrawal(BTC, amount, "1Address", "")
+		if err == nil {
+			t.Errorf("expected error for withdrawing %v", amount)
+		}
+	}
+}
+
+func TestQueryAndCancelCoinWithdrawal(t *testing.T) {
+	newTestAPI(t, &CoinStatus, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("id") != "270" {
+			t.Errorf("unexpected status query: %s", r.URL.RawQuery)
+		}
+		fmt.Fprint(w, `[{"id": "270", "type": "coin-out", "currency": "btc", "amount": "0.5",
+			"fee": "0.001", "status": "queued", "createdAt": 1500000000000,
+			"details": {"address": "1Address"}}]`)
+	})
+	k := newTestAPI(t, &CancelWithdrawal, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"status": "not_cancelable"}`)
+	})
+
+	transfer, err := k.QueryCoinWithdrawal(BTC, 270)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if transfer.Status != "queued" || transfer.Amount != 0.5 || transfer.Details.Address != "1Address" {
+		t.Errorf("unexpected transfer: %+v", transfer)
+	}
+
+	_, err = k.QueryCoinDeposit(BTC, 270)
+	if err == nil {
+		t.Error("expected error for a withdrawal queried as a deposit")
+	}
+
+	resp, err := k.CancelCoinWithdrawal(BTC, 270)
+	if err == nil || resp.Status != "not_cancelable" || resp.TransferID != 270 {
+		t.Errorf("unexpected cancel: %+v %v", resp, err)
+	}
+}
+
+func TestGetDepositAddresses(t *testing.T) {
+	k := newTestAPI(t, &WalletURL, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Query().Get("currency_pair") {
+		case BTCKRW:
+			fmt.Fprint(w, `{"in": [{"currency": "btc", "address": {"address": "1Deposit"}},
+				{"currency": "krw", "address": {"bank": "KEB", "account": "123"}}]}`)
+		case XRPKRW:
+			fmt.Fprint(w, `{"in": [{"currency": "xrp",
+				"address": {"address": "rDeposit", "destination_tag": "99"}}]}`)
+		default:
+			fmt.Fprint(w, `{"in": []}`)
+		}
+	})
+
+	addresses, err := k.GetDepositAddresses()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(addresses) != 2 {
+		t.Fatalf("unexpected addresses: %v", addresses)
+	}
+	if addresses[BTC].Address != "1Deposit" {
+		t.Errorf("unexpected btc address: %+v", addresses[BTC])
+	}
+	if addresses[XRP] != (DepositAddress{Currency: XRP, Address: "rDeposit", DestinationTag: "99"}) {
+		t.Errorf("unexpected xrp address: %+v", addresses[XRP])
+	}
+}
+
+func TestAssignDepositAddress(t *testing.T) {
+	k := newTestAPI(t, &AssignAddress, func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		if r.Form.Get("currency") != ETH {
+			t.Errorf("unexpected currency: %v", r.Form)
+		}
+		fmt.Fprint(w, `{"status": "success", "address": "0xDeposit"}`)
+	})
+
+	addr, err := k.AssignDepositAddress(ETH)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *addr != (DepositAddress{Currency: ETH, Address: "0xDeposit"}) {
+		t.Errorf("unexpected address: %+v", addr)
+	}
+}
+
+func TestPendingCoinDeposits(t *testing.T) {
+	k := newTestAPI(t, &CoinStatus, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `[
+			{"id": "1", "type": "coin-in", "currency": "btc", "amount": "1", "status": "pending",
+				"confirmations": 2},
+			{"id": "2", "type": "coin-in", "currency": "btc", "amount": "1", "status": "done",
+				"completedAt": 1500000000000},
+			{"id": "3", "type": "coin-out", "currency": "btc", "amount": "1", "status": "queued"}
+		]`)
+	})
+
+	pending, err := k.PendingCoinDeposits(BTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pending) != 1 || pending[0].ID != 1 || pending[0].Confirmations != 2 {
+		t.Errorf("unexpected pending deposits: %+v", pending)
+	}
+}
@@ -0,0 +1,334 @@
+package korbit
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ErrWithdrawalDenied is the cause of the errors for withdrawals stopped by the policy.
+var ErrWithdrawalDenied = errors.New("withdrawal denied by policy")
+
+// AllowedAddress is an address that withdrawals may go to. When DestinationTag is set the
+// withdrawal must use the same tag.
+type AllowedAddress struct {
+	Address        string `json:"address"`
+	DestinationTag string `json:"destination_tag,omitempty"`
+}
+
+// WithdrawalAttempt is a withdrawal as it was asked for.
+type WithdrawalAttempt struct {
+	Currency       string  `json:"currency"`
+	Amount         float64 `json:"amount"`
+	Address        string  `json:"address"`
+	DestinationTag string  `json:"destination_tag,omitempty"`
+}
+
+// WithdrawalPolicy is checked before a withdrawal leaves the process. The limits are per
+// currency, a currency without a limit has no limit of that kind but a currency without
+// allowed addresses can not be withdrawn at all. KRW goes to the registered bank account
+// so its allowed addresses are account numbers.
+type WithdrawalPolicy struct {
+	AllowedAddresses map[string][]AllowedAddress
+	MaxPerWithdrawal map[string]float64
+	MaxPerDay        map[string]float64 // over the last 24 hours
+
+	// withdrawals above ConfirmAbove are only sent when Confirm returns true.
+	ConfirmAbove map[string]float64
+	Confirm      func(WithdrawalAttempt) bool
+}
+
+// AuditAttempt and AuditResult are the stages of the audit entries. Every attempt is
+// written before it is sent, and the allowed ones are written again with their result.
+const (
+	AuditAttempt = "attempt"
+	AuditResult  = "result"
+)
+
+// AuditEntry is written to the audit log for every withdrawal attempt.
+type AuditEntry struct {
+	Time  time.Time `json:"time"`
+	Stage string    `json:"stage"`
+	WithdrawalAttempt
+	Allowed    bool   `json:"allowed"`
+	Reason     string `json:"reason,omitempty"`
+	TransferID int64  `json:"transfer_id,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+// withdrawalRecord is a withdrawal counted against the daily limit.
+type withdrawalRecord struct {
+	time     time.Time
+	currency string
+	amount   float64
+}
+
+// GuardedWithdrawer sends coin and KRW withdrawals only when the policy allows them and
+// writes every attempt to the audit log as a line of JSON. The daily limit counts the
+// withdrawals made through the withdrawer, Record and ReplayAuditLog add the ones made
+// before it started.
+type GuardedWithdrawer struct {
+	Policy   WithdrawalPolicy
+	AuditLog io.Writer
+
+	send        func(currency string, amount float64, address, tag string) (*WithdrawalResponse, error)
+	sendFiat    func(amount int64) (*WithdrawalResponse, error)
+	bankAccount func() (*AcctInfo, error)
+	now         func() time.Time
+
+	mu      sync.Mutex
+	history []withdrawalRecord
+}
+
+// NewGuardedWithdrawer returns a withdrawer that sends through the api and audits to
+// stderr until AuditLog is changed.
+func NewGuardedWithdrawer(k *API, policy WithdrawalPolicy) *GuardedWithdrawer {
+	return &GuardedWithdrawer{
+		Policy:      policy,
+		AuditLog:    os.Stderr,
+		send:        k.RequestCoinWithdrawal,
+		sendFiat:    k.RequestFiatWithdrawal,
+		bankAccount: k.GetBankAccount,
+		now:         time.Now,
+	}
+}
+
+// Record counts a withdrawal that was made at t against the daily limit.
+func (g *GuardedWithdrawer) Record(t time.Time, currency string, amount float64) {
+	if !validAmount(amount) {
+		return
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.history = append(g.history, withdrawalRecord{time: t, currency: currency, amount: amount})
+}
+
+// ReplayAuditLog counts the withdrawals in an audit log written by an earlier run against
+// the daily limit, so that the limit holds across runs that share the log.
+func (g *GuardedWithdrawer) ReplayAuditLog(r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+
+		var entry AuditEntry
+		err := json.Unmarshal(scanner.Bytes(), &entry)
+		if err != nil {
+			return errors.Wrapf(err, "withdrawal audit log line %d", line)
+		}
+
+		// the results are left out so that each withdrawal is only counted once.
+		if entry.Stage == AuditAttempt && entry.Allowed {
+			g.Record(entry.Time, entry.Currency, entry.Amount)
+		}
+	}
+	return errors.Wrap(scanner.Err(), "read withdrawal audit log")
+}
+
+// RequestCoinWithdrawal is API.RequestCoinWithdrawal behind the policy. Denied
+// withdrawals give an error with ErrWithdrawalDenied as the cause, and nothing is sent
+// when the attempt can not be written to the audit log.
+func (g *GuardedWithdrawer) RequestCoinWithdrawal(currency string, amount float64, address,
+	destinationTag string) (*WithdrawalResponse, error) {
+
+	attempt := WithdrawalAttempt{
+		Currency:       currency,
+		Amount:         amount,
+		Address:        address,
+		DestinationTag: destinationTag,
+	}
+
+	return g.withdraw(attempt, func() (*WithdrawalResponse, error) {
+		return g.send(currency, amount, address, destinationTag)
+	})
+}
+
+// RequestFiatWithdrawal is API.RequestFiatWithdrawal behind the policy, with the account
+// number of the registered bank account as the address.
+func (g *GuardedWithdrawer) RequestFiatWithdrawal(amount int64) (*WithdrawalResponse, error) {
+	acct, err := g.bankAccount()
+	if err != nil {
+		return nil, errors.Wrap(err, "bank account to withdraw to")
+	}
+
+	attempt := WithdrawalAttempt{Currency: KRW, Amount: float64(amount), Address: acct.Account}
+	return g.withdraw(attempt, func() (*WithdrawalResponse, error) {
+		return g.sendFiat(amount)
+	})
+}
+
+// Check gives the error that the attempt would be denied with, without sending it or
+// writing it to the audit log. The confirmation is not asked for. A KRW attempt without
+// an address is checked against the registered bank account like RequestFiatWithdrawal.
+func (g *GuardedWithdrawer) Check(attempt WithdrawalAttempt) error {
+	if attempt.Currency == KRW && attempt.Address == "" {
+		acct, err := g.bankAccount()
+		if err != nil {
+			return errors.Wrap(err, "bank account to withdraw to")
+		}
+		attempt.Address = acct.Account
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	reason := g.check(attempt, g.now())
+	if reason != "" {
+		return errors.Wrap(ErrWithdrawalDenied, reason)
+	}
+	return nil
+}
+
+// withdraw checks and audits the attempt and sends it when it is allowed.
+func (g *GuardedWithdrawer) withdraw(attempt WithdrawalAttempt, send func() (*WithdrawalResponse, error)) (
+	*WithdrawalResponse, error) {
+
+	g.mu.Lock()
+	reason := g.check(attempt, g.now())
+	g.mu.Unlock()
+
+	// Confirm may wait on a person so it is asked without the lock, and the limits are
+	// checked again after it since other withdrawals could have been made meanwhile.
+	if reason == "" && !g.Policy.confirmed(attempt) {
+		reason = "withdrawal was not confirmed"
+	}
+
+	// the lock is held through sending so that two withdrawals can not both fit under
+	// the daily limit.
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	now := g.now()
+	entry := AuditEntry{Time: now, Stage: AuditAttempt, WithdrawalAttempt: attempt}
+	if reason == "" {
+		reason = g.check(attempt, now)
+	}
+	if reason != "" {
+		entry.Reason = reason
+		if !validAmount(attempt.Amount) {
+			entry.Amount = 0 // NaN and Inf can not be written as JSON, the reason has it
+		}
+		err := g.audit(entry)
+		if err != nil {
+			return nil, err
+		}
+		return nil, errors.Wrap(ErrWithdrawalDenied, reason)
+	}
+
+	entry.Allowed = true
+	err := g.audit(entry)
+	if err != nil {
+		return nil, err
+	}
+
+	// a failed request may still have gone through, so it counts against the limit too.
+	g.history = append(g.history, withdrawalRecord{time: now, currency: attempt.Currency,
+		amount: attempt.Amount})
+
+	resp, err := send()
+
+	result := AuditEntry{Time: g.now(), Stage: AuditResult, WithdrawalAttempt: attempt, Allowed: true}
+	if resp != nil {
+		result.TransferID = resp.TransferID
+	}
+	if err != nil {
+		result.Error = err.Error()
+	}
+	auditErr := g.audit(result)
+
+	if err != nil {
+		return resp, err
+	}
+	return resp, auditErr
+}
+
+// check gives the reason the attempt is denied by the addresses and limits, empty when
+// it is allowed. It must be called with the lock held.
+func (g *GuardedWithdrawer) check(a WithdrawalAttempt, now time.Time) string {
+	p := g.Policy
+
+	// a negative amount would make room under the daily limit and NaN would break it.
+	if !validAmount(a.Amount) {
+		return fmt.Sprintf("amount %v is not a positive number", a.Amount)
+	}
+
+	dayAgo := now.Add(-24 * time.Hour)
+	var kept []withdrawalRecord
+	for _, r := range g.history {
+		if r.time.After(dayAgo) {
+			kept = append(kept, r)
+		}
+	}
+	g.history = kept
+
+	allowed := false
+	for _, addr := range p.AllowedAddresses[a.Currency] {
+		if addr.Address == a.Address && (addr.DestinationTag == "" || addr.DestinationTag == a.DestinationTag) {
+			allowed = true
+			break
+		}
+	}
+	if !allowed {
+		return "address is not on the allow list"
+	}
+
+	if limit, ok := p.MaxPerWithdrawal[a.Currency]; ok && a.Amount > limit {
+		return "amount is over the per withdrawal limit"
+	}
+
+	if limit, ok := p.MaxPerDay[a.Currency]; ok {
+		total := a.Amount
+		for _, r := range g.history {
+			if r.currency == a.Currency {
+				total += r.amount
+			}
+		}
+
+		if total > limit {
+			return "amount is over the 24 hour limit"
+		}
+	}
+
+	return ""
+}
+
+// confirmed reports whether the attempt needs no confirmation or Confirm gave it.
+func (p WithdrawalPolicy) confirmed(a WithdrawalAttempt) bool {
+	threshold, ok := p.ConfirmAbove[a.Currency]
+	if !ok || a.Amount <= threshold {
+		return true
+	}
+	return p.Confirm != nil && p.Confirm(a)
+}
+
+func validAmount(amount float64) bool {
+	return amount > 0 && !math.IsInf(amount, 0)
+}
+
+// audit writes the entry as a line of JSON.
+func (g *GuardedWithdrawer) audit(entry AuditEntry) error {
+	if g.AuditLog == nil {
+		return errors.New("withdrawal audit log is not set")
+	}
+
+	b, err := json.Marshal(entry)
+	if err != nil {
+		return errors.Wrap(err, "marshal withdrawal audit entry")
+	}
+
+	_, err = g.AuditLog.Write(append(b, '\n'))
+	if err != nil {
+		return errors.Wrap(err, "write withdrawal audit entry")
+	}
+	return nil
+}
@@ -0,0 +1,268 @@
+package korbit
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+func TestGuardedWithdrawer(t *testing.T) {
+	var sent []WithdrawalAttempt
+	var audit bytes.Buffer
+	confirmed := false
+	now := time.Unix(1500000000, 0)
+
+	g := NewGuardedWithdrawer(NewKorbitAPI("", "", "", ""), WithdrawalPolicy{
+		AllowedAddresses: map[string][]AllowedAddress{
+			BTC: {{Address: "1Cold"}},
+			XRP: {{Address: "rExchange", DestinationTag: "42"}},
+		},
+		MaxPerWithdrawal: map[string]float64{BTC: 1},
+		MaxPerDay:        map[string]float64{BTC: 1.5},
+		ConfirmAbove:     map[string]float64{XRP: 1000},
+		Confirm:          func(WithdrawalAttempt) bool { return confirmed },
+	})
+	g.AuditLog = &audit
+	g.now = func() time.Time { return now }
+	g.send = func(currency string, amount float64, address, tag string) (*WithdrawalResponse, error) {
+		sent = append(sent, WithdrawalAttempt{currency, amount, address, tag})
+		return &WithdrawalResponse{TransferID: int64(len(sent)), Status: Success}, nil
+	}
+
+	attempts := []struct {
+		attempt WithdrawalAttempt
+		allowed bool
+	}{
+		{WithdrawalAttempt{BTC, 0.5, "1Cold", ""}, true},
+		{WithdrawalAttempt{BTC, 0.5, "1Hot", ""}, false},       // not on the allow list
+		{WithdrawalAttempt{ETH, 1, "0xCold", ""}, false},       // no addresses for eth
+		{WithdrawalAttempt{BTC, 1.1, "1Cold", ""}, false},      // over the per withdrawal limit
+		{WithdrawalAttempt{BTC, 0.75, "1Cold", ""}, true},      // 1.25 in the last day
+		{WithdrawalAttempt{BTC, 0.5, "1Cold", ""}, false},      // 1.75 would be over the day
+		{WithdrawalAttempt{XRP, 500, "rExchange", "7"}, false}, // wrong destination tag
+		{WithdrawalAttempt{XRP, 5000, "rExchange", "42"}, false},
+	}
+
+	for i, a := range attempts {
+		_, err := g.RequestCoinWithdrawal(a.attempt.Currency, a.attempt.Amount, a.attempt.Address,
+			a.attempt.DestinationTag)
+		if a.allowed && err != nil {
+			t.Errorf("attempt %d: %v", i, err)
+		}
+		if !a.allowed && errors.Cause(err) != ErrWithdrawalDenied {
+			t.Errorf("attempt %d: got %v, want it denied", i, err)
+		}
+	}
+
+	// confirmed large withdrawals go through and the day rolls over for btc.
+	confirmed = true
+	now = now.Add(25 * time.Hour)
+	_, err := g.RequestCoinWithdrawal(XRP, 5000, "rExchange", "42")
+	if err != nil {
+		t.Error(err)
+	}
+	_, err = g.RequestCoinWithdrawal(BTC, 1, "1Cold", "")
+	if err != nil {
+		t.Error(err)
+	}
+
+	if len(sent) != 4 {
+		t.Errorf("sent %d withdrawals, want 4: %v", len(sent), sent)
+	}
+
+	// every denied attempt is one line, every allowed one is a line before and after.
+	lines := strings.Split(strings.TrimSpace(audit.String()), "\n")
+	if len(lines) != 6+4*2 {
+		t.Fatalf("got %d audit lines, want 14", len(lines))
+	}
+
+	var entry AuditEntry
+	err = json.Unmarshal([]byte(lines[2]), &entry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entry.Allowed || entry.Address != "1Hot" || entry.Reason == "" {
+		t.Errorf("unexpected audit entry: %+v", entry)
+	}
+}
+
+func TestGuardedWithdrawerInvalidAmount(t *testing.T) {
+	var sent []float64
+	var audit bytes.Buffer
+
+	g := NewGuardedWithdrawer(NewKorbitAPI("", "", "", ""), WithdrawalPolicy{
+		AllowedAddresses: map[string][]AllowedAddress{BTC: {{Address: "1Cold"}}},
+		MaxPerDay:        map[string]float64{BTC: 1},
+	})
+	g.AuditLog = &audit
+	g.send = func(currency string, amount float64, address, tag string) (*WithdrawalResponse, error) {
+		sent = append(sent, amount)
+		return &WithdrawalResponse{TransferID: int64(len(sent)), Status: Success}, nil
+	}
+
+	for _, amount := range []float64{-5, 0, math.NaN(), math.Inf(1)} {
+		_, err := g.RequestCoinWithdrawal(BTC, amount, "1Cold", "")
+		if errors.Cause(err) != ErrWithdrawalDenied {
+			t.Errorf("amount %v: got %v, want it denied", amount, err)
+		}
+	}
+	g.Record(time.Now(), BTC, -5)
+
+	// nothing above made room under the daily limit or poisoned it.
+	_, err := g.RequestCoinWithdrawal(BTC, 5, "1Cold", "")
+	if errors.Cause(err) != ErrWithdrawalDenied {
+		t.Errorf("got %v, want 5 btc over the daily limit", err)
+	}
+	_, err = g.RequestCoinWithdrawal(BTC, 0.5, "1Cold", "")
+	if err != nil {
+		t.Error(err)
+	}
+
+	if len(sent) != 1 {
+		t.Errorf("sent %v, want only the 0.5 btc", sent)
+	}
+	if lines := strings.Split(strings.TrimSpace(audit.String()), "\n"); len(lines) != 4+1+2 {
+		t.Errorf("got %d audit lines, want 7", len(lines))
+	}
+}
+
+func TestGuardedFiatWithdrawalAndReplay(t *testing.T) {
+	var audit bytes.Buffer
+	policy := WithdrawalPolicy{
+		AllowedAddresses: map[string][]AllowedAddress{KRW: {{Address: "110-123-456789"}}},
+		MaxPerDay:        map[string]float64{KRW: 1000000},
+	}
+
+	newWithdrawer := func(account string) (*GuardedWithdrawer, *[]int64) {
+		var sent []int64
+		g := NewGuardedWithdrawer(NewKorbitAPI("", "", "", ""), policy)
+		g.AuditLog = &audit
+		g.bankAccount = func() (*AcctInfo, error) { return &AcctInfo{Bank: "shinhan", Account: account}, nil }
+		g.sendFiat = func(amount int64) (*WithdrawalResponse, error) {
+			sent = append(sent, amount)
+			return &WithdrawalResponse{TransferID: int64(len(sent)), Status: Success}, nil
+		}
+		return g, &sent
+	}
+
+	g, sent := newWithdrawer("110-123-456789")
+	_, err := g.RequestFiatWithdrawal(600000)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a later run that shares the audit log still counts the first withdrawal.
+	later, laterSent := newWithdrawer("110-123-456789")
+	if err := later.ReplayAuditLog(bytes.NewReader(audit.Bytes())); err != nil {
+		t.Fatal(err)
+	}
+	logged := audit.Len()
+	err = later.Check(WithdrawalAttempt{Currency: KRW, Amount: 600000})
+	if errors.Cause(err) != ErrWithdrawalDenied || audit.Len() != logged {
+		t.Errorf("got %v, want it over the daily limit without an audit entry", err)
+	}
+	if err := later.Check(WithdrawalAttempt{Currency: KRW, Amount: 400000}); err != nil {
+		t.Error(err)
+	}
+	_, err = later.RequestFiatWithdrawal(600000)
+	if errors.Cause(err) != ErrWithdrawalDenied {
+		t.Errorf("got %v, want it over the daily limit", err)
+	}
+
+	other, otherSent := newWithdrawer("999-999")
+	_, err = other.RequestFiatWithdrawal(1000)
+	if errors.Cause(err) != ErrWithdrawalDenied {
+		t.Errorf("got %v, want an account that is not allowed denied", err)
+	}
+
+	if len(*sent) != 1 || len(*laterSent) != 0 || len(*otherSent) != 0 {
+		t.Errorf("unexpected withdrawals: %v %v %v", *sent, *laterSent, *otherSent)
+	}
+}
+
+func TestGuardedWithdrawerConfirmWithoutLock(t *testing.T) {
+	var sent []float64
+	var audit bytes.Buffer
+	now := time.Unix(1500000000, 0)
+
+	var g *GuardedWithdrawer
+	g = NewGuardedWithdrawer(NewKorbitAPI("", "", "", ""), WithdrawalPolicy{
+		AllowedAddresses: map[string][]AllowedAddress{BTC: {{Address: "1Cold"}}},
+		MaxPerDay:        map[string]float64{BTC: 1},
+		ConfirmAbove:     map[string]float64{BTC: 0.1},
+		Confirm: func(a WithdrawalAttempt) bool {
+			// another withdrawal is made while this one waits for the confirmation.
+			_, err := g.RequestCoinWithdrawal(BTC, 0.1, "1Cold", "")
+			if err != nil {
+				t.Error(err)
+			}
+			g.Record(now, BTC, 0.5)
+			return true
+		},
+	})
+	g.AuditLog = &audit
+	g.now = func() time.Time { return now }
+	g.send = func(currency string, amount float64, address, tag string) (*WithdrawalResponse, error) {
+		sent = append(sent, amount)
+		return &WithdrawalResponse{TransferID: int64(len(sent)), Status: Success}, nil
+	}
+
+	// 0.5 fits under the limit when it is asked for but not once it is confirmed.
+	_, err := g.RequestCoinWithdrawal(BTC, 0.5, "1Cold", "")
+	if errors.Cause(err) != ErrWithdrawalDenied {
+		t.Errorf("got %v, want it over the daily limit after the confirmation", err)
+	}
+	if len(sent) != 1 || sent[0] != 0.1 {
+		t.Errorf("sent %v, want only the 0.1 btc", sent)
+	}
+}
+
+func TestReplayAuditLog(t *testing.T) {
+	now := time.Now()
+	entry := func(stage string, allowed bool, amount float64) string {
+		b, err := json.Marshal(AuditEntry{Time: now, Stage: stage, Allowed: allowed,
+			WithdrawalAttempt: WithdrawalAttempt{Currency: BTC, Amount: amount, Address: "1Cold"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(b)
+	}
+
+	// the result of the 0.5 btc has no transfer id and the log was edited by hand.
+	log := strings.Join([]string{
+		entry(AuditAttempt, true, 0.5),
+		entry(AuditResult, true, 0.5),
+		"",
+		entry(AuditAttempt, false, 2),
+		"  ",
+		entry(AuditAttempt, true, 0.25),
+	}, "\n") + "\n\n"
+
+	g := NewGuardedWithdrawer(NewKorbitAPI("", "", "", ""), WithdrawalPolicy{
+		AllowedAddresses: map[string][]AllowedAddress{BTC: {{Address: "1Cold"}}},
+		MaxPerDay:        map[string]float64{BTC: 1},
+	})
+	g.AuditLog = &bytes.Buffer{}
+	g.send = func(currency string, amount float64, address, tag string) (*WithdrawalResponse, error) {
+		return &WithdrawalResponse{Status: Success}, nil
+	}
+
+	err := g.ReplayAuditLog(strings.NewReader(log))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = g.RequestCoinWithdrawal(BTC, 0.25, "1Cold", "")
+	if err != nil {
+		t.Errorf("got %v, want 1 btc in the day to be allowed", err)
+	}
+	_, err = g.RequestCoinWithdrawal(BTC, 0.01, "1Cold", "")
+	if errors.Cause(err) != ErrWithdrawalDenied {
+		t.Errorf("got %v, want it over the daily limit", err)
+	}
+}